@@ -0,0 +1,349 @@
+// gconfctl 查看及监听gconf配置的命令行工具。
+//
+//	gconfctl get <app> <key>
+//	gconfctl list <app>
+//	gconfctl dump <app> [--format json|yaml|env|kubernetes] [--namespace ns]
+//	gconfctl watch <app>
+//	gconfctl diff <app> --region <a> --region <b>
+//	gconfctl decrypt <encryptedPassword>...
+//
+// 服务地址与gconf.Init的推断规则一致，可通过--region或--endpoint指定。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconf_export"
+)
+
+const usage = `usage: gconfctl <command> [flags] [args]
+
+commands:
+  get <app> <key>                 print the raw value of a key
+  list <app>                      list keys of a collection
+  dump <app> [--format f]         dump a collection as json, yaml, env or kubernetes
+  watch <app>                     stream changes as json lines until interrupted
+  diff <app> --region a --region b
+                                  compare a collection between two regions
+  decrypt <encryptedPassword>...  decrypt passwords with the global public key
+
+flags:
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gconfctl:", err)
+		os.Exit(1)
+	}
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+type command struct {
+	regions     stringList
+	endpoints   stringList
+	format      string
+	namespace   string
+	timeout     time.Duration
+	showSecrets bool
+	args        []string
+	out         io.Writer
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(strings.TrimSpace(usage))
+	}
+	name := args[0]
+	cmd := &command{out: out}
+	fs := flag.NewFlagSet("gconfctl "+name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprint(out, usage)
+		fs.PrintDefaults()
+	}
+	fs.Var(&cmd.regions, "region", "work region such as prod-sh, or a gconf url; repeat twice for diff")
+	fs.Var(&cmd.endpoints, "endpoint", "gconf url such as http://gconf/api; repeat for failover")
+	fs.StringVar(&cmd.format, "format", "json", "dump format: json, yaml, env or kubernetes")
+	fs.StringVar(&cmd.namespace, "namespace", "", "namespace of the kubernetes manifests")
+	fs.DurationVar(&cmd.timeout, "timeout", time.Second*10, "timeout for loading a collection")
+	fs.BoolVar(&cmd.showSecrets, "show-secrets", false, "do not redact sensitive values in watch and diff output")
+	if err := parseInterspersed(fs, args[1:], &cmd.args); err != nil {
+		return err
+	}
+
+	switch name {
+	case "get":
+		return cmd.get(ctx)
+	case "list":
+		return cmd.list(ctx)
+	case "dump":
+		return cmd.dump(ctx)
+	case "watch":
+		return cmd.watch(ctx)
+	case "diff":
+		return cmd.diff(ctx)
+	case "decrypt":
+		return cmd.decrypt(ctx)
+	case "help", "-h", "--help":
+		fs.Usage()
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", name)
+}
+
+// 允许参数与flag交替出现，如 get app key --region prod-sh
+func parseInterspersed(fs *flag.FlagSet, args []string, positional *[]string) error {
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			return nil
+		}
+		*positional = append(*positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func (cmd *command) expectArgs(n int, names string) error {
+	if len(cmd.args) != n {
+		return fmt.Errorf("expected arguments: %s", names)
+	}
+	return nil
+}
+
+// 创建客户端，region为空时使用--endpoint或默认推断规则，region包含://时视为服务地址
+func (cmd *command) newClient(region string) *gconf.Client {
+	opts := []gconf.Option{
+		gconf.WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	}
+	if strings.Contains(region, "://") {
+		opts = append(opts, gconf.WithEndpoints(region))
+	} else if region != "" {
+		opts = append(opts, gconf.WithRegion(region))
+	} else if len(cmd.endpoints) > 0 {
+		opts = append(opts, gconf.WithEndpoints(cmd.endpoints...))
+	}
+	return gconf.NewClient("gconfctl", opts...)
+}
+
+func (cmd *command) region() (string, error) {
+	if len(cmd.regions) > 1 {
+		return "", errors.New("--region may be given only once for this command")
+	}
+	if len(cmd.regions) == 1 {
+		return cmd.regions[0], nil
+	}
+	return "", nil
+}
+
+func (cmd *command) load(ctx context.Context, c *gconf.Client, appId string) (*gconf.ConfigCollection, error) {
+	ctx, cancel := context.WithTimeout(ctx, cmd.timeout)
+	defer cancel()
+	if err := c.WaitReady(ctx, appId); err != nil {
+		return nil, err
+	}
+	return c.GetConfigCollection(appId), nil
+}
+
+// 加载args[0]对应的配置集合
+func (cmd *command) loadCollection(ctx context.Context) (*gconf.Client, *gconf.ConfigCollection, error) {
+	region, err := cmd.region()
+	if err != nil {
+		return nil, nil, err
+	}
+	c := cmd.newClient(region)
+	collection, err := cmd.load(ctx, c, cmd.args[0])
+	if err != nil {
+		c.Close()
+		return nil, nil, err
+	}
+	return c, collection, nil
+}
+
+func (cmd *command) get(ctx context.Context) error {
+	if err := cmd.expectArgs(2, "<app> <key>"); err != nil {
+		return err
+	}
+	c, collection, err := cmd.loadCollection(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	v := collection.GetValue(cmd.args[1])
+	if v == nil {
+		return fmt.Errorf("key %s not found in %s", cmd.args[1], cmd.args[0])
+	}
+	fmt.Fprintln(cmd.out, v.Raw())
+	return nil
+}
+
+func (cmd *command) list(ctx context.Context) error {
+	if err := cmd.expectArgs(1, "<app>"); err != nil {
+		return err
+	}
+	c, collection, err := cmd.loadCollection(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for _, k := range sortedKeys(collection.AsMap()) {
+		fmt.Fprintln(cmd.out, k)
+	}
+	return nil
+}
+
+func (cmd *command) dump(ctx context.Context) error {
+	if err := cmd.expectArgs(1, "<app>"); err != nil {
+		return err
+	}
+	format, err := gconf_export.ParseFormat(cmd.format)
+	if err != nil {
+		return err
+	}
+	c, collection, err := cmd.loadCollection(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return gconf_export.Export(cmd.out, collection, format, gconf_export.WithNamespace(cmd.namespace))
+}
+
+type change struct {
+	Time     time.Time `json:"time"`
+	AppId    string    `json:"appId"`
+	Key      string    `json:"key"`
+	OldValue string    `json:"oldValue"`
+	NewValue string    `json:"newValue"`
+}
+
+func (cmd *command) watch(ctx context.Context) error {
+	if err := cmd.expectArgs(1, "<app>"); err != nil {
+		return err
+	}
+	c, collection, err := cmd.loadCollection(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	redactor := gconf.NewRedactor(gconf.DefaultRedactKeys, gconf.DefaultRedactValues)
+	enc := json.NewEncoder(cmd.out)
+	collection.AddCollectionChangeListener(gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		if !cmd.showSecrets {
+			oldValue, newValue = redactor.Redact(key, oldValue), redactor.Redact(key, newValue)
+		}
+		enc.Encode(change{Time: time.Now(), AppId: cmd.args[0], Key: key, OldValue: oldValue, NewValue: newValue})
+	}))
+	<-ctx.Done()
+	return nil
+}
+
+func (cmd *command) diff(ctx context.Context) error {
+	if err := cmd.expectArgs(1, "<app>"); err != nil {
+		return err
+	}
+	if len(cmd.regions) != 2 {
+		return errors.New("diff needs exactly two --region flags")
+	}
+	var data [2]map[string]string
+	for i, region := range cmd.regions {
+		c := cmd.newClient(region)
+		collection, err := cmd.load(ctx, c, cmd.args[0])
+		c.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", region, err)
+		}
+		data[i] = collection.AsMap()
+	}
+
+	redactor := gconf.NewRedactor(gconf.DefaultRedactKeys, gconf.DefaultRedactValues)
+	show := func(key, value string) string {
+		if !cmd.showSecrets {
+			value = redactor.Redact(key, value)
+		}
+		return strings.ReplaceAll(value, "\n", "\n    ")
+	}
+	keys := map[string]bool{}
+	for _, d := range data {
+		for k := range d {
+			keys[k] = true
+		}
+	}
+	differs := false
+	for _, k := range sortedKeys(keys) {
+		a, inA := data[0][k]
+		b, inB := data[1][k]
+		switch {
+		case inA && !inB:
+			fmt.Fprintf(cmd.out, "- %s (only in %s)\n", k, cmd.regions[0])
+		case !inA && inB:
+			fmt.Fprintf(cmd.out, "+ %s (only in %s)\n", k, cmd.regions[1])
+		case a != b:
+			fmt.Fprintf(cmd.out, "~ %s\n  %s:\n    %s\n  %s:\n    %s\n", k, cmd.regions[0], show(k, a), cmd.regions[1], show(k, b))
+		default:
+			continue
+		}
+		differs = true
+	}
+	if !differs {
+		fmt.Fprintln(cmd.out, "no differences")
+	}
+	return nil
+}
+
+func (cmd *command) decrypt(ctx context.Context) error {
+	if len(cmd.args) == 0 {
+		return errors.New("expected arguments: <encryptedPassword>...")
+	}
+	region, err := cmd.region()
+	if err != nil {
+		return err
+	}
+	c := cmd.newClient(region)
+	defer c.Close()
+	if _, err := cmd.load(ctx, c, "golang"); err != nil {
+		return err
+	}
+	for _, encrypted := range cmd.args {
+		pwd := c.Decrypt(encrypted)
+		if pwd == "" {
+			return errors.New("failed to decrypt " + encrypted)
+		}
+		fmt.Fprintln(cmd.out, pwd)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+func newServer(t *testing.T, data map[string]string) *gconftest.Server {
+	s := gconftest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetCollection("app", data)
+	return s
+}
+
+func runCmd(t *testing.T, args ...string) string {
+	t.Helper()
+	out := new(bytes.Buffer)
+	if err := run(context.Background(), args, out); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	return out.String()
+}
+
+func TestGetListDump(t *testing.T) {
+	s := newServer(t, map[string]string{"a.properties": "x=1\ny=2", "b": `say "hi"`})
+
+	if out := runCmd(t, "get", "app", "b", "--endpoint", s.URL); out != "say \"hi\"\n" {
+		t.Fatalf("unexpected get output %q", out)
+	}
+	if out := runCmd(t, "list", "--endpoint", s.URL, "app"); out != "a.properties\nb\n" {
+		t.Fatalf("unexpected list output %q", out)
+	}
+	if out := runCmd(t, "dump", "app", "--endpoint", s.URL, "--format", "env"); out != "# gconf-key: a.properties\nA_PROPERTIES=\"x=1\\ny=2\"\n# gconf-key: b\nB=\"say \\\"hi\\\"\"\n" {
+		t.Fatalf("unexpected env output %q", out)
+	}
+	m := map[string]string{}
+	if err := json.Unmarshal([]byte(runCmd(t, "dump", "app", "--endpoint", s.URL)), &m); err != nil || m["b"] != `say "hi"` {
+		t.Fatalf("unexpected json output %v %v", m, err)
+	}
+	if out := runCmd(t, "dump", "app", "--endpoint", s.URL, "--format", "yaml"); !strings.Contains(out, "a.properties: |-\n  x=1\n  y=2\n") {
+		t.Fatalf("unexpected yaml output %q", out)
+	}
+
+	if out := runCmd(t, "dump", "app", "--endpoint", s.URL, "--format", "k8s", "--namespace", "ns"); !strings.Contains(out, "kind: ConfigMap") || !strings.Contains(out, "namespace: ns") {
+		t.Fatalf("unexpected kubernetes output %q", out)
+	}
+
+	if err := run(context.Background(), []string{"get", "app", "missing", "--endpoint", s.URL}, new(bytes.Buffer)); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := newServer(t, map[string]string{"same": "1", "changed": "a", "onlyA": "x", "db.password": "p1"})
+	b := newServer(t, map[string]string{"same": "1", "changed": "b", "onlyB": "y", "db.password": "p2"})
+	out := runCmd(t, "diff", "app", "--region", a.URL, "--region", b.URL)
+	for _, want := range []string{"~ changed\n", "- onlyA (only in " + a.URL, "+ onlyB (only in " + b.URL, "~ db.password\n  " + a.URL + ":\n    [REDACTED]"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("diff output %q does not contain %q", out, want)
+		}
+	}
+	if strings.Contains(out, "same") {
+		t.Fatalf("equal keys should not be reported: %q", out)
+	}
+}
+
+type syncBuffer struct {
+	mux sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	s := newServer(t, map[string]string{"a": "1"})
+	ctx, cancel := context.WithCancel(context.Background())
+	out := new(syncBuffer)
+	done := make(chan error)
+	go func() { done <- run(ctx, []string{"watch", "app", "--endpoint", s.URL}, out) }()
+
+	deadline := time.Now().Add(time.Second * 5)
+	for len(s.Requests("/watch")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	s.Set("app", "a", "2")
+	for !strings.Contains(out.String(), `"newValue":"2"`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("change not streamed: %q", out.String())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecrypt(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pub, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("golang", map[string]string{"publicKey": base64.StdEncoding.EncodeToString(pub)})
+	encrypted, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.Hash(0), []byte("secret"))
+
+	if out := runCmd(t, "decrypt", "--endpoint", s.URL, base64.StdEncoding.EncodeToString(encrypted)); out != "secret\n" {
+		t.Fatalf("unexpected decrypt output %q", out)
+	}
+}
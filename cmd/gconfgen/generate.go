@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+// 生成的结构体，嵌套的json对象生成独立的结构体
+type structType struct {
+	name   string
+	key    string // 对应的配置key，嵌套结构体为空
+	tag    string // config或json
+	fields []field
+}
+
+type field struct {
+	name, typ, tag string
+}
+
+type generator struct {
+	structs []*structType
+	names   map[string]bool // 已使用的标识符
+}
+
+func generate(pkg, appId string, data map[string]string) ([]byte, error) {
+	g := &generator{names: map[string]bool{"AppId": true}}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	consts := make([]string, len(keys))
+	for i, k := range keys {
+		consts[i] = g.uniqueName("Key" + identifier(baseName(k)))
+	}
+	var roots []*structType
+	for _, k := range keys {
+		var s *structType
+		switch {
+		case strings.HasSuffix(k, ".properties"):
+			s = g.propertiesStruct(k, data[k])
+		case strings.HasSuffix(k, ".json"):
+			s = g.jsonStruct(k, data[k])
+		}
+		if s != nil {
+			roots = append(roots, s)
+		}
+	}
+
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "// Code generated by gconfgen; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(roots) > 0 {
+		b.WriteString("import (\n\"fmt\"\n\n\"github.com/guanaitong/gconf-go-client\"\n)\n\n")
+	}
+	fmt.Fprintf(b, "// 配置集合\nconst AppId = %q\n\n", appId)
+	if len(keys) > 0 {
+		b.WriteString("// 配置key\nconst (\n")
+		for i, k := range keys {
+			fmt.Fprintf(b, "%s = %q\n", consts[i], k)
+		}
+		b.WriteString(")\n")
+	}
+	for _, s := range g.structs {
+		if s.key != "" {
+			fmt.Fprintf(b, "\n// %s\n", s.key)
+		} else {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(b, "type %s struct {\n", s.name)
+		for _, f := range s.fields {
+			fmt.Fprintf(b, "%s %s `%s:%q`\n", f.name, f.typ, s.tag, f.tag)
+		}
+		b.WriteString("}\n")
+	}
+	for i, k := range keys {
+		for _, s := range roots {
+			if s.key != k {
+				continue
+			}
+			fmt.Fprintf(b, `
+// 注册%[2]s，配置变化时自动更新
+func Register%[1]s(c *gconf.ConfigCollection) (*%[1]s, error) {
+	v := c.GetValue(%[3]s)
+	if v == nil {
+		return nil, fmt.Errorf("key %%s not found in %%s", %[3]s, c.AppId())
+	}
+	x := new(%[1]s)
+	if err := v.Register(x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+`, s.name, k, consts[i])
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+func baseName(key string) string {
+	for _, ext := range []string{".properties", ".json"} {
+		if strings.HasSuffix(key, ext) {
+			return strings.TrimSuffix(key, ext)
+		}
+	}
+	return key
+}
+
+// properties的值推断为bool、int、float64或string
+func (g *generator) propertiesStruct(key, value string) *structType {
+	s := &structType{name: g.uniqueName(identifier(baseName(key))), key: key, tag: "config"}
+	g.structs = append(g.structs, s)
+	props := gconf.ParseProperties(value)
+	names := map[string]bool{}
+	for _, k := range sortedKeys(props) {
+		s.fields = append(s.fields, field{name: uniqueIn(names, identifier(k)), typ: scalarType(props[k]), tag: k})
+	}
+	return s
+}
+
+func scalarType(v string) string {
+	if lower := strings.ToLower(v); lower == "true" || lower == "false" {
+		return "bool"
+	}
+	if _, err := strconv.ParseInt(v, 10, 0); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return "float64"
+	}
+	return "string"
+}
+
+// 顶层不是对象的json不生成结构体
+func (g *generator) jsonStruct(key, value string) *structType {
+	var m map[string]any
+	dec := json.NewDecoder(strings.NewReader(value))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil
+	}
+	return g.objectStruct(identifier(baseName(key)), key, m)
+}
+
+func (g *generator) objectStruct(name, key string, m map[string]any) *structType {
+	s := &structType{name: g.uniqueName(name), key: key, tag: "json"}
+	g.structs = append(g.structs, s)
+	names := map[string]bool{}
+	for _, k := range sortedKeys(m) {
+		fieldName := uniqueIn(names, identifier(k))
+		s.fields = append(s.fields, field{name: fieldName, typ: g.jsonType(s.name+fieldName, m[k]), tag: k})
+	}
+	return s
+}
+
+func (g *generator) jsonType(name string, v any) string {
+	switch x := v.(type) {
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case json.Number:
+		if i, err := x.Int64(); err == nil && i >= math.MinInt32 && i <= math.MaxInt32 {
+			return "int"
+		} else if err == nil {
+			return "int64"
+		}
+		return "float64"
+	case map[string]any:
+		return "*" + g.objectStruct(name, "", x).name
+	case []any:
+		if len(x) == 0 {
+			return "[]any"
+		}
+		elem := g.jsonType(name+"Item", x[0])
+		for _, e := range x[1:] {
+			if _, ok := e.(map[string]any); ok {
+				continue // 对象数组以第一个元素的字段为准
+			}
+			if t := g.jsonType(name+"Item", e); t != elem {
+				return "[]any"
+			}
+		}
+		return "[]" + elem
+	}
+	return "any"
+}
+
+var initialisms = map[string]bool{
+	"api": true, "db": true, "dsn": true, "http": true, "id": true, "ip": true,
+	"json": true, "sql": true, "tls": true, "ttl": true, "uri": true, "url": true,
+}
+
+// 将配置key或字段名转换为导出的Go标识符，如max_idle-conns -> MaxIdleConns、userId -> UserID
+func identifier(s string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+
+	b := new(strings.Builder)
+	for _, w := range words {
+		lower := strings.ToLower(w)
+		if initialisms[lower] {
+			b.WriteString(strings.ToUpper(lower))
+			continue
+		}
+		rs := []rune(lower)
+		rs[0] = unicode.ToUpper(rs[0])
+		b.WriteString(string(rs))
+	}
+	id := b.String()
+	if id == "" || !unicode.IsLetter([]rune(id)[0]) {
+		id = "X" + id
+	}
+	return id
+}
+
+func (g *generator) uniqueName(name string) string {
+	return uniqueIn(g.names, name)
+}
+
+func uniqueIn(names map[string]bool, name string) string {
+	res := name
+	for i := 2; names[res]; i++ {
+		res = name + strconv.Itoa(i)
+	}
+	names[res] = true
+	return res
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+// gconfgen 根据配置集合生成类型化的Go代码，配合go generate使用，key拼写错误在编译期即可发现。
+//
+//	//go:generate go run github.com/guanaitong/gconf-go-client/cmd/gconfgen -app userdoor -o gconf_gen.go
+//
+// 每个.properties/.json配置生成一个结构体及Register函数，每个配置生成一个key常量。
+// 配置来源与gconfctl一致，默认按gconf.Init的规则连接服务端，可通过-region或-endpoint
+// 指定(如gconftest启动的服务端)，也可通过-snapshot读取gconfctl dump导出的文件。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconf_export"
+)
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "gconfgen:", err)
+		os.Exit(1)
+	}
+}
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	var (
+		appId, region, snapshot, format, pkg, output string
+		endpoints                                    stringList
+		timeout                                      time.Duration
+	)
+	fs := flag.NewFlagSet("gconfgen", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	fs.StringVar(&appId, "app", "", "config collection to generate code for (required)")
+	fs.StringVar(&region, "region", "", "work region such as prod-sh")
+	fs.Var(&endpoints, "endpoint", "gconf url such as http://gconf/api; repeat for failover")
+	fs.StringVar(&snapshot, "snapshot", "", "read the collection from a file written by gconfctl dump instead of gconf")
+	fs.StringVar(&format, "format", "", "snapshot format: json, yaml, env or kubernetes; inferred from the file extension by default")
+	fs.StringVar(&pkg, "package", os.Getenv("GOPACKAGE"), "package name of the generated file; $GOPACKAGE under go generate")
+	fs.StringVar(&output, "o", "", "output file; stdout by default")
+	fs.DurationVar(&timeout, "timeout", time.Second*10, "timeout for loading the collection")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if appId == "" {
+		return errors.New("-app is required")
+	}
+	if pkg == "" {
+		pkg = "config"
+	}
+
+	var data map[string]string
+	var err error
+	if snapshot != "" {
+		data, err = readSnapshot(snapshot, format)
+	} else {
+		data, err = load(ctx, appId, region, endpoints, timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	src, err := generate(pkg, appId, data)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+func readSnapshot(path, format string) (map[string]string, error) {
+	if format == "" {
+		switch filepath.Ext(path) {
+		case ".yaml", ".yml":
+			format = string(gconf_export.YAML)
+		case ".env":
+			format = string(gconf_export.Dotenv)
+		default:
+			format = string(gconf_export.JSON)
+		}
+	}
+	f, err := gconf_export.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return gconf_export.Read(file, f)
+}
+
+func load(ctx context.Context, appId, region string, endpoints []string, timeout time.Duration) (map[string]string, error) {
+	opts := []gconf.Option{
+		gconf.WithLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	}
+	if region != "" {
+		opts = append(opts, gconf.WithRegion(region))
+	} else if len(endpoints) > 0 {
+		opts = append(opts, gconf.WithEndpoints(endpoints...))
+	}
+	c := gconf.NewClient("gconfgen", opts...)
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := c.WaitReady(ctx, appId); err != nil {
+		return nil, err
+	}
+	return c.GetConfigCollection(appId).AsMap(), nil
+}
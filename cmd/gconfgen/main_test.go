@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+var testData = map[string]string{
+	"deny.properties": "# comment\npath=/tmp/impower\nperiod=10\nhas=true\nenabled=1\nratio=0.5",
+	"datasource.json": `{"dbName":"app","servers":[{"host":"a","port":3306}],"tags":["x"],"pool":{"maxIdle":3},"big":12345678901}`,
+	"list.json":       `[1,2]`,
+	"banner":          "hi",
+	"2fa-secret":      "s",
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := generate("config", "userdoor", testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(src)
+	for _, want := range []string{
+		"package config",
+		`const AppId = "userdoor"`,
+		`KeyBanner     = "banner"`,
+		`KeyX2faSecret = "2fa-secret"`,
+		`KeyList       = "list.json"`,
+		"type Deny struct",
+		"Period  int     `config:\"period\"`",
+		"Has     bool    `config:\"has\"`",
+		"Enabled int     `config:\"enabled\"`",
+		"Ratio   float64 `config:\"ratio\"`",
+		"Pool    *DatasourcePool          `json:\"pool\"`",
+		"Servers []*DatasourceServersItem `json:\"servers\"`",
+		"Big     int64                    `json:\"big\"`",
+		"func RegisterDeny(c *gconf.ConfigCollection) (*Deny, error)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("generated code does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "type List ") {
+		t.Errorf("non-object json should not generate a struct:\n%s", out)
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	for in, want := range map[string]string{
+		"max_idle-conns": "MaxIdleConns",
+		"userId":         "UserID",
+		"HTTPServer":     "HTTPServer",
+		"redis.url":      "RedisURL",
+		"9lives":         "X9lives",
+		"":               "X",
+	} {
+		if got := identifier(in); got != want {
+			t.Errorf("identifier(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// 从gconftest服务端生成代码，并使用生成的代码读取配置，确认能通过编译且字段匹配
+func TestGeneratedCodeCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a program with the go tool")
+	}
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("userdoor", testData)
+
+	dir, err := os.MkdirTemp(".", "testgen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-app", "userdoor", "-endpoint", s.URL, "-package", "config", "-o", filepath.Join(dir, "config", "gconf_gen.go")}
+	if err := run(context.Background(), args, new(bytes.Buffer)); err != nil {
+		t.Fatal(err)
+	}
+	prog := `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/cmd/gconfgen/` + filepath.Base(dir) + `/config"
+)
+
+func main() {
+	c := gconf.NewClient(config.AppId, gconf.WithEndpoints(os.Args[1]))
+	defer c.Close()
+	if err := c.WaitReady(context.Background(), config.AppId); err != nil {
+		panic(err)
+	}
+	collection := c.GetConfigCollection(config.AppId)
+	deny, err := config.RegisterDeny(collection)
+	if err != nil {
+		panic(err)
+	}
+	ds, err := config.RegisterDatasource(collection)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(deny.Path, deny.Period, deny.Has, deny.Enabled, deny.Ratio, ds.DBName, ds.Servers[0].Port, ds.Pool.MaxIdle, collection.GetValue(config.KeyBanner).Raw())
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(prog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("go", "run", "./"+dir, s.URL).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if got, want := strings.TrimSpace(string(out)), "/tmp/impower 10 true 1 0.5 app 3306 3 hi"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "userdoor.env")
+	if err := os.WriteFile(path, []byte("banner=\"hi\"\n# gconf-key: redis-config.json\nREDIS_CONFIG_JSON=\"{}\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	if err := run(context.Background(), []string{"-app", "userdoor", "-snapshot", path}, out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `= "banner"`) || !strings.Contains(out.String(), `KeyRedisConfig = "redis-config.json"`) || strings.Contains(out.String(), "REDIS_CONFIG_JSON") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
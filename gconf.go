@@ -1,143 +1,314 @@
 package gconf
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"os"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-var currentAppId string
-var clientId string
-var ds *dataStore
+var std *Client
 
 // call first  at program start palace
-func Init(appName string) {
+func Init(appName string, opts ...Option) {
 	appNameFromEnv := os.Getenv("APP_NAME")
 	if appNameFromEnv != "" && appNameFromEnv != appName {
 		panic(fmt.Sprintf("appName[%s]与环境变量中值[%s]不一致", appName, appNameFromEnv))
 	}
-	currentAppId = appName
-	inK8s := len(os.Getenv("KUBERNETES_SERVICE_HOST")) > 0
+	if std != nil {
+		std.Close()
+	}
+	std = NewClient(appName, opts...)
+}
 
-	//在k8s里，使用HOSTNAME，VM里使用APP_INSTANCE_NAME
+// 返回Init创建的默认客户端
+func Default() *Client {
+	return std
+}
 
-	getEnv := func(key, defaultValue string) string {
-		value := os.Getenv(key)
-		if value == "" {
-			return defaultValue
-		}
-		return value
-	}
-	domain := "gconf"
-
-	if !inK8s {
-		domainSuffix := "dev.ofc"
-		workRegion := getEnv("WORK_REGION", "dev-ofc")
-		if workRegion == "dev-ofc" {
-			domainSuffix = "dev.ofc"
-		} else if workRegion == "test-ali" {
-			domainSuffix = "test.ali"
-		} else if workRegion == "stage-sh" {
-			domainSuffix = "product.sh"
-		} else if workRegion == "prod-sh" {
-			domainSuffix = "product.sh"
-		} else if workRegion == "stage-lyra" {
-			domainSuffix = "product.lyra"
-		} else if workRegion == "prod-lyra" {
-			domainSuffix = "product.lyra"
-		}
-		domain = "gconf.services." + domainSuffix
-	}
+// 替换默认客户端，不会关闭原客户端，主要用于测试
+func SetDefault(c *Client) {
+	std = c
+}
+
+// gconf客户端，维护配置集合缓存并在后台监听配置变化
+type Client struct {
+	appId          string
+	clientId       string
+	dataCache      map[string]*ConfigCollection
+	loading        map[string]*loadCall     // 正在获取的配置集合，由mux保护
+	lookupFailures map[string]lookupFailure // 占位符引用的集合获取失败的记录，由mux保护
+	source         source
+	httpClient     *gConfHttpClient // 使用内存配置源时为nil
+	observer       Observer
+	logger         *slog.Logger
+	redactor       *Redactor
+	tracer         trace.Tracer
+	mux            sync.RWMutex
+	ctx            context.Context
+	cancel         context.CancelFunc
+
+	readyAppIds   map[string]bool // 就绪检查需要等待的appId
+	interpolation bool            // 是否解析值中的占位符，见WithInterpolation
+	profiles      []string        // 生效的profile，未启用WithProfiles时为nil
+	instance      string          // 实例名，用于定向规则
+	labels        []string
+	region        string
+}
+
+// 创建一个独立的客户端，不影响Init创建的默认客户端。不再使用时需调用Close
+func NewClient(appName string, opts ...Option) *Client {
+	o := newOptions(opts)
+
+	//在k8s里，使用HOSTNAME，VM里使用APP_INSTANCE_NAME
 	var appInstance string
-	if inK8s {
+	if inK8s() {
 		appInstance = getEnv("HOSTNAME", "unknown")
 	} else {
 		appInstance = getEnv("APP_INSTANCE_NAME", "unknown")
 	}
-	clientId = appName + "-->" + appInstance + "-->" + fmt.Sprint(rand.Int63n(time.Now().UnixNano()))
+	clientId := appName + "-->" + appInstance + "-->" + fmt.Sprint(rand.Int63n(time.Now().UnixNano()))
 
-	ds = &dataStore{
-		dataCache: map[string]*ConfigCollection{},
-		client: &gConfHttpClient{
-			baseUrl:  "http://" + domain + "/api",
-			clientId: clientId,
-		},
-		mux: sync.Mutex{},
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		appId:          appName,
+		clientId:       clientId,
+		dataCache:      map[string]*ConfigCollection{},
+		loading:        map[string]*loadCall{},
+		lookupFailures: map[string]lookupFailure{},
+		observer:       o.observer,
+		logger:         o.logger.With("component", "gconf"),
+		redactor:       o.redactor,
+		tracer:         o.tracer.Tracer(tracerName),
+		ctx:            ctx,
+		cancel:         cancel,
+
+		readyAppIds:   map[string]bool{},
+		interpolation: o.interpolation,
+	}
+	if o.profiles {
+		c.instance = appInstance
+		c.labels, c.region = instanceLabels(o.labels, o.region)
+		c.profiles = activeProfiles(c.labels, c.region)
 	}
-	ds.startBackgroundTask()
+	if o.memory != nil {
+		c.source = newMemorySource(o.memory)
+	} else {
+		c.httpClient = newGConfHttpClient(o.endpoints, clientId)
+		c.httpClient.observer = o.observer
+		c.httpClient.tracer = c.tracer
+		c.httpClient.propagator = o.propagator
+		c.source = c.httpClient
+		c.startProbeTask(o.probeInterval)
+	}
+	c.startBackgroundTask()
+	return c
+}
+
+// 返回客户端的应用名
+func (c *Client) AppId() string {
+	return c.appId
+}
+
+// 停止后台监听，已获取的配置集合不再更新
+func (c *Client) Close() {
+	c.cancel()
+}
+
+// 返回各gconf服务地址的健康状态
+func (c *Client) Endpoints() []EndpointStatus {
+	if c.httpClient == nil {
+		return []EndpointStatus{}
+	}
+	return c.httpClient.endpointStatus()
+}
+
+// 立即从配置来源拉取appId的配置集合，集合尚未获取时先获取。
+// 配置变化会在当前goroutine里触发监听器
+func (c *Client) Refresh(ctx context.Context, appId string) error {
+	collection, err := c.loadConfigCollection(ctx, appId)
+	if collection == nil || err != nil {
+		return err
+	}
+	return collection.refreshData(ctx)
 }
 
-type dataStore struct {
-	dataCache map[string]*ConfigCollection
-	client    *gConfHttpClient
-	mux       sync.Mutex
+func (c *Client) collections() []*ConfigCollection {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	res := make([]*ConfigCollection, 0, len(c.dataCache))
+	for _, v := range c.dataCache {
+		res = append(res, v)
+	}
+	return res
 }
 
-func (ds *dataStore) startBackgroundTask() {
+func (c *Client) startBackgroundTask() {
 	go func() {
-		for {
-			if len(ds.dataCache) == 0 {
-				time.Sleep(time.Second * 2)
+		for c.ctx.Err() == nil {
+			collections := c.collections()
+			if len(collections) == 0 {
+				c.sleep(time.Second * 2)
 				continue
 			}
 			var appIdList []string
-			for k := range ds.dataCache {
-				appIdList = append(appIdList, k)
+			for _, collection := range collections {
+				if !collection.isLoaded() { //首次加载失败的，在这里重试
+					collection.refreshData(c.ctx)
+				}
+				appIdList = append(appIdList, collection.appId)
+			}
+			needChangeAppIdList, err := c.source.watch(c.ctx, appIdList)
+			if c.ctx.Err() != nil {
+				return
+			}
+			c.observer.ObserveWatch(appIdList, len(needChangeAppIdList), err)
+			if err != nil { //所有地址均不可用时，稍后重试
+				c.logger.Warn("watch failed", "appIds", appIdList, "error", err)
+				for _, collection := range collections {
+					collection.health.failed(err)
+				}
+				c.sleep(time.Second)
+				continue
 			}
-			needChangeAppIdList := ds.client.watch(appIdList)
 
+			needChange := map[string]bool{}
 			for _, appId := range needChangeAppIdList {
-				ds.dataCache[appId].refreshData(ds.client)
+				needChange[appId] = true
+			}
+			for _, collection := range collections {
+				collection.health.watched()
+				if !needChange[collection.appId] {
+					if collection.isLoaded() { //首次加载仍失败时不能标记为已加载
+						collection.markSynced()
+					}
+				} else {
+					collection.refreshData(c.ctx)
+				}
 			}
 		}
 	}()
 }
 
-func (ds *dataStore) getConfigCollection(appId string) *ConfigCollection {
-	res, ok := ds.dataCache[appId]
-	if ok {
-		return res
+func (c *Client) startProbeTask(interval time.Duration) {
+	if len(c.httpClient.endpoints) < 2 {
+		return
+	}
+	go func() {
+		for c.sleep(interval) {
+			c.httpClient.probe(c.ctx)
+		}
+	}()
+}
+
+// 返回false表示客户端已关闭
+func (c *Client) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-c.ctx.Done():
+		return false
 	}
+}
+
+func (c *Client) getConfigCollection(appId string) *ConfigCollection {
+	res, _ := c.loadConfigCollection(c.ctx, appId)
+	return res
+}
 
-	ds.mux.Lock()
-	defer ds.mux.Unlock()
+// 正在进行的配置集合获取，同一appId并发获取时只请求一次
+type loadCall struct {
+	done chan struct{}
+	res  *ConfigCollection
+	err  error
+}
 
-	//double check
-	res, ok = ds.dataCache[appId]
+// 获取配置集合，集合创建成功但首次加载失败时，同时返回集合和错误。
+// 请求服务端时不持有mux，避免地址不可用时阻塞其他集合的读取
+func (c *Client) loadConfigCollection(ctx context.Context, appId string) (res *ConfigCollection, err error) {
+	c.mux.RLock()
+	res, ok := c.dataCache[appId]
+	c.mux.RUnlock()
 	if ok {
-		return res
+		return res, nil
+	}
+
+	c.mux.Lock()
+	//double check
+	if res, ok = c.dataCache[appId]; ok {
+		c.mux.Unlock()
+		return res, nil
+	}
+	if call, ok := c.loading[appId]; ok {
+		c.mux.Unlock()
+		select {
+		case <-call.done:
+			return call.res, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
+	call := &loadCall{done: make(chan struct{})}
+	c.loading[appId] = call
+	c.mux.Unlock()
 
-	configApp := ds.client.getConfigApp(appId)
-	if configApp == nil {
-		return nil
+	call.res, call.err = c.fetchConfigCollection(ctx, appId)
+	c.mux.Lock()
+	if call.res != nil {
+		c.dataCache[appId] = call.res
 	}
+	delete(c.loading, appId)
+	c.mux.Unlock()
+	close(call.done)
+	return call.res, call.err
+}
+
+func (c *Client) fetchConfigCollection(ctx context.Context, appId string) (res *ConfigCollection, err error) {
+	ctx, span := c.tracer.Start(ctx, "gconf.getConfigCollection", trace.WithAttributes(attrAppId.String(appId)))
+	defer func() { endSpan(span, err) }()
 
-	res = &ConfigCollection{
-		appId:     appId,
-		name:      configApp.Name,
-		data:      map[string]*Value{},
-		listeners: map[string][]ConfigChangeListener{},
+	configApp, err := c.source.getConfigApp(ctx, appId)
+	if err != nil {
+		return nil, err
 	}
-	res.refreshData(ds.client)
-	ds.dataCache[appId] = res
-	return res
+	res = newConfigCollection(c, appId, configApp.Name)
+	return res, res.refreshData(ctx)
+}
+
+// 获取当前应用的配置集合
+func (c *Client) GetCurrentConfigCollection() *ConfigCollection {
+	return c.GetConfigCollection(c.appId)
+}
+
+// 获取全局的配置配置集合，此方法用于框架的统一配置。
+func (c *Client) GetGlobalConfigCollection() *ConfigCollection {
+	return c.GetConfigCollection("golang")
+}
+
+// 获取某个appId的配置集合
+func (c *Client) GetConfigCollection(appId string) *ConfigCollection {
+	return c.getConfigCollection(appId)
 }
 
 // 获取当前应用的配置集合
 func GetCurrentConfigCollection() *ConfigCollection {
-	return GetConfigCollection(currentAppId)
+	return std.GetCurrentConfigCollection()
 }
 
 // 获取全局的配置配置集合，此方法用于框架的统一配置。
 // 应用不需要调用此方法
 func GetGlobalConfigCollection() *ConfigCollection {
-	return GetConfigCollection("golang")
+	return std.GetGlobalConfigCollection()
 }
 
 // 获取某个appId的配置集合
 func GetConfigCollection(appId string) *ConfigCollection {
-	return ds.getConfigCollection(appId)
+	return std.GetConfigCollection(appId)
 }
@@ -1,6 +1,7 @@
 package gconf
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,11 +9,57 @@ import (
 	"net/http"
 	u "net/url"
 	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type gConfHttpClient struct {
-	baseUrl  string
-	clientId string
+	endpoints  []*endpoint
+	current    int // 当前使用的地址下标，请求成功后保持不变，直到失败或更优先的地址恢复
+	clientId   string
+	observer   Observer
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	mux        sync.Mutex
+}
+
+// gconf服务地址及其健康状态
+type endpoint struct {
+	baseUrl   string
+	healthy   bool
+	failures  int
+	lastError error
+	lastCheck time.Time
+}
+
+// 服务地址的健康状态
+type EndpointStatus struct {
+	Url       string    `json:"url"`
+	Healthy   bool      `json:"healthy"`
+	Current   bool      `json:"current"`
+	Failures  int       `json:"failures"`
+	LastError string    `json:"lastError,omitempty"`
+	LastCheck time.Time `json:"lastCheck"`
+}
+
+func newGConfHttpClient(baseUrls []string, clientId string) *gConfHttpClient {
+	g := &gConfHttpClient{
+		clientId:   clientId,
+		observer:   nopObserver{},
+		tracer:     noop.NewTracerProvider().Tracer(tracerName),
+		propagator: propagation.TraceContext{},
+	}
+	for _, baseUrl := range baseUrls {
+		g.endpoints = append(g.endpoints, &endpoint{
+			baseUrl: strings.TrimSuffix(baseUrl, "/"),
+			healthy: true,
+		})
+	}
+	return g
 }
 
 type ConfigApp struct {
@@ -21,24 +68,24 @@ type ConfigApp struct {
 }
 
 // 获取configApp信息
-func (g *gConfHttpClient) getConfigApp(appId string) *ConfigApp {
-	content, err := g.getContent("/getConfigApp", map[string]string{
+func (g *gConfHttpClient) getConfigApp(ctx context.Context, appId string) (*ConfigApp, error) {
+	content, err := g.getContent(ctx, "/getConfigApp", map[string]string{
 		"configAppId": appId,
 	})
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	configApp := new(ConfigApp)
 	err = json.Unmarshal([]byte(content), configApp)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("parse config app %s: %w", appId, err)
 	}
-	return configApp
+	return configApp, nil
 }
 
 // 获取配置集合Key列表
-func (g *gConfHttpClient) listConfigKeys(appId string) []string {
-	content, err := g.getContent("/listConfigKeys", map[string]string{
+func (g *gConfHttpClient) listConfigKeys(ctx context.Context, appId string) []string {
+	content, err := g.getContent(ctx, "/listConfigKeys", map[string]string{
 		"configAppId": appId,
 	})
 	if err != nil {
@@ -53,8 +100,8 @@ func (g *gConfHttpClient) listConfigKeys(appId string) []string {
 }
 
 // 获取单个配置项值
-func (g *gConfHttpClient) getConfig(appId, key string) string {
-	content, err := g.getContent("/getConfig", map[string]string{
+func (g *gConfHttpClient) getConfig(ctx context.Context, appId, key string) string {
+	content, err := g.getContent(ctx, "/getConfig", map[string]string{
 		"configAppId": appId,
 		"key":         key,
 	})
@@ -65,45 +112,88 @@ func (g *gConfHttpClient) getConfig(appId, key string) string {
 }
 
 // 获取所有配置
-func (g *gConfHttpClient) listConfigs(appId string) map[string]string {
-	content, err := g.getContent("/listConfigs", map[string]string{
+func (g *gConfHttpClient) listConfigs(ctx context.Context, appId string) (map[string]string, error) {
+	content, err := g.getContent(ctx, "/listConfigs", map[string]string{
 		"configAppId": appId,
 	})
 	if err != nil {
-		return map[string]string{}
+		return nil, err
 	}
 	res := make(map[string]string)
 	err = json.Unmarshal([]byte(content), &res)
 	if err != nil {
-		return res
+		return nil, fmt.Errorf("parse configs of %s: %w", appId, err)
 	}
-	return res
+	return res, nil
 }
 
 // 监听appid列表，返回需要更新的appId
-func (g *gConfHttpClient) watch(configAppIds []string) []string {
+func (g *gConfHttpClient) watch(ctx context.Context, configAppIds []string) ([]string, error) {
 	configAppIdList := strings.Join(configAppIds, ",")
-	content, err := g.getContent("/watch", map[string]string{
+	content, err := g.getContent(ctx, "/watch", map[string]string{
 		"configAppIdList": configAppIdList,
 		"clientId":        g.clientId,
 	})
-	if err != nil || content == "" {
-		return []string{}
+	if err != nil {
+		return nil, err
+	}
+	if content == "" {
+		return []string{}, nil
 	}
 	keys := make([]string, 0)
 	err = json.Unmarshal([]byte(content), &keys)
 	if err != nil {
-		return []string{}
+		return []string{}, nil
 	}
-	return keys
+	return keys, nil
 }
 
-func (g *gConfHttpClient) getContent(path string, params map[string]string) (string, error) {
-	url := g.baseUrl + path
+// 按优先级依次尝试各地址，连接错误或5xx时切换到下一个地址
+func (g *gConfHttpClient) getContent(ctx context.Context, path string, params map[string]string) (content string, err error) {
+	ctx, span := g.tracer.Start(ctx, "gconf.getContent", trace.WithAttributes(attrPath.String(path)))
+	attempts := 0
+	defer func() {
+		span.SetAttributes(attrAttempts.Int(attempts))
+		endSpan(span, err)
+	}()
+	if appId, ok := params["configAppId"]; ok {
+		span.SetAttributes(attrAppId.String(appId))
+	}
 	var values = make(u.Values)
 	for k, v := range params {
 		values.Set(k, fmt.Sprint(v))
 	}
+	var lastErr error
+	for _, e := range g.candidates() {
+		attempts++
+		content, retryable, err := g.getContentFrom(ctx, e, path, values)
+		if err == nil {
+			g.markSuccess(e)
+			span.SetAttributes(attrEndpoint.String(e.baseUrl))
+			return content, nil
+		}
+		lastErr = err
+		if !retryable || ctx.Err() != nil {
+			return "", err
+		}
+		g.markFailure(e, err)
+	}
+	return "", lastErr
+}
+
+func (g *gConfHttpClient) getContentFrom(ctx context.Context, e *endpoint, path string, values u.Values) (content string, retryable bool, err error) {
+	ctx, span := g.tracer.Start(ctx, "gconf.request", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrPath.String(path), attrEndpoint.String(e.baseUrl)))
+	start := time.Now()
+	statusCode := 0
+	defer func() {
+		g.observer.ObserveRequest(e.baseUrl, path, statusCode, time.Since(start), err)
+		if statusCode != 0 {
+			span.SetAttributes(attrStatusCode.Int(statusCode))
+		}
+		endSpan(span, err)
+	}()
+	url := e.baseUrl + path
 	if len(values) > 0 {
 		if strings.Contains(url, "?") {
 			url = url + "&" + values.Encode()
@@ -111,22 +201,124 @@ func (g *gConfHttpClient) getContent(path string, params map[string]string) (str
 			url = url + "?" + values.Encode()
 		}
 	}
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	g.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
+		return "", true, err
 	}
 
 	if resp.Body != nil {
 		defer resp.Body.Close()
 	}
+	statusCode = resp.StatusCode
 
 	if resp.StatusCode == http.StatusOK {
 		bs, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return "", err
+			return "", true, err
 		}
-		return string(bs), nil
+		return string(bs), false, nil
 	} else {
-		return "", errors.New("resp status code is not 200, it it " + resp.Status + " ,url is " + url)
+		return "", resp.StatusCode >= http.StatusInternalServerError, errors.New("resp status code is not 200, it it " + resp.Status + " ,url is " + url)
 	}
 }
+
+// 返回本次请求的地址尝试顺序：当前地址优先，然后是其他健康地址，最后是不健康的地址
+func (g *gConfHttpClient) candidates() []*endpoint {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	res := make([]*endpoint, 0, len(g.endpoints))
+	var unhealthy []*endpoint
+	for i := range g.endpoints {
+		e := g.endpoints[(g.current+i)%len(g.endpoints)]
+		if e.healthy {
+			res = append(res, e)
+		} else {
+			unhealthy = append(unhealthy, e)
+		}
+	}
+	return append(res, unhealthy...)
+}
+
+func (g *gConfHttpClient) markSuccess(e *endpoint) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.current = g.setHealthy(e)
+}
+
+// 标记地址恢复，若其优先级高于当前地址则切回
+func (g *gConfHttpClient) markRecovered(e *endpoint) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if i := g.setHealthy(e); i < g.current {
+		g.current = i
+	}
+}
+
+// 调用方需持有锁，返回地址下标
+func (g *gConfHttpClient) setHealthy(e *endpoint) int {
+	e.healthy = true
+	e.failures = 0
+	e.lastError = nil
+	e.lastCheck = time.Now()
+	for i, x := range g.endpoints {
+		if x == e {
+			return i
+		}
+	}
+	return g.current
+}
+
+func (g *gConfHttpClient) markFailure(e *endpoint, err error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	e.healthy = false
+	e.failures++
+	e.lastError = err
+	e.lastCheck = time.Now()
+}
+
+// 探测不可用的地址，恢复后标记为健康；若其优先级高于当前地址，则切回该地址
+func (g *gConfHttpClient) probe(ctx context.Context) {
+	g.mux.Lock()
+	var failed []*endpoint
+	for _, e := range g.endpoints {
+		if !e.healthy {
+			failed = append(failed, e)
+		}
+	}
+	g.mux.Unlock()
+
+	for _, e := range failed {
+		_, retryable, err := g.getContentFrom(ctx, e, "/getConfigApp", u.Values{"configAppId": {"golang"}})
+		if err != nil && retryable {
+			g.markFailure(e, err)
+			continue
+		}
+		g.markRecovered(e)
+	}
+}
+
+func (g *gConfHttpClient) endpointStatus() []EndpointStatus {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	res := make([]EndpointStatus, 0, len(g.endpoints))
+	for i, e := range g.endpoints {
+		s := EndpointStatus{
+			Url:       e.baseUrl,
+			Healthy:   e.healthy,
+			Current:   i == g.current,
+			Failures:  e.failures,
+			LastCheck: e.lastCheck,
+		}
+		if e.lastError != nil {
+			s.LastError = e.lastError.Error()
+		}
+		res = append(res, s)
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package gconf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGConfHttpClientFailover(t *testing.T) {
+	var down int32 = 1
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"configCollectionId":"app","name":"primary"}`))
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"configCollectionId":"app","name":"backup"}`))
+	}))
+	defer backup.Close()
+
+	g := newGConfHttpClient([]string{primary.URL, backup.URL}, "test")
+	ctx := context.Background()
+
+	if app, _ := g.getConfigApp(ctx, "app"); app == nil || app.Name != "backup" {
+		t.Fatalf("expected failover to backup, got %v", app)
+	}
+	status := g.endpointStatus()
+	if status[0].Healthy || !status[1].Healthy || !status[1].Current {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// 主地址恢复前，保持使用备用地址
+	g.probe(ctx)
+	if app, _ := g.getConfigApp(ctx, "app"); app == nil || app.Name != "backup" {
+		t.Fatalf("expected sticky backup, got %v", app)
+	}
+
+	atomic.StoreInt32(&down, 0)
+	g.probe(ctx)
+	if app, _ := g.getConfigApp(ctx, "app"); app == nil || app.Name != "primary" {
+		t.Fatalf("expected return to primary, got %v", app)
+	}
+	if status := g.endpointStatus(); !status[0].Healthy || !status[0].Current {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestGConfHttpClientNoFailoverOn4xx(t *testing.T) {
+	var backupHits int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&backupHits, 1)
+	}))
+	defer backup.Close()
+
+	g := newGConfHttpClient([]string{primary.URL, backup.URL}, "test")
+	if _, err := g.getContent(context.Background(), "/getConfig", nil); err == nil {
+		t.Fatal("expected error")
+	}
+	if atomic.LoadInt32(&backupHits) != 0 {
+		t.Fatal("4xx should not fail over")
+	}
+	if status := g.endpointStatus(); !status[0].Healthy {
+		t.Fatalf("4xx should not mark endpoint unhealthy: %+v", status)
+	}
+}
@@ -1,7 +1,12 @@
 package gconf
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 该方法会在gconf后台同步goroutine里执行，请保证该方法不要有阻塞。不然会影响gconf更新。
@@ -12,73 +17,302 @@ type ConfigChangeListener interface {
 	valueChanged(key, oldValue, newValue string)
 }
 
+// 函数形式的监听器，便于在其他包中注册
+type ConfigChangeListenerFunc func(key, oldValue, newValue string)
+
+func (f ConfigChangeListenerFunc) valueChanged(key, oldValue, newValue string) {
+	f(key, oldValue, newValue)
+}
+
 // 配置集合
 type ConfigCollection struct {
-	appId     string
-	name      string
-	data      map[string]*Value //这里用map不线程安全不要紧，数据不会从map中移除，value指针会替换
-	listeners map[string][]ConfigChangeListener
+	client       *Client
+	appId        string
+	name         string
+	data         map[string]*Value //数据不会从map中移除，新增key时由dataMux保护
+	effective    map[string]*Value // 启用WithProfiles时基础key生效的值，由dataMux保护
+	targeted     []string          // 定向规则命中的profile，由dataMux保护
+	assignments  []Assignment      // 定向规则的评估结果，由dataMux保护
+	dataMux      sync.RWMutex
+	listeners    map[string][]*listenerEntry
+	anyLis       []*listenerEntry // 监听所有key
+	targetingLis []*TargetingChangeListener
+	lisMux       sync.RWMutex
+
+	refreshMux sync.Mutex    // 保证同一时刻只有一个刷新
+	loaded     chan struct{} // 首次加载成功后关闭
+	health     collectionHealth
 }
 
-// 获取key对应的配置
+func newConfigCollection(client *Client, appId, name string) *ConfigCollection {
+	return &ConfigCollection{
+		client:    client,
+		appId:     appId,
+		name:      name,
+		data:      map[string]*Value{},
+		effective: map[string]*Value{},
+		listeners: map[string][]*listenerEntry{},
+		loaded:    make(chan struct{}),
+	}
+}
+
+// 配置集合的appId
+func (c *ConfigCollection) AppId() string {
+	return c.appId
+}
+
+// 配置集合的名称
+func (c *ConfigCollection) Name() string {
+	return c.name
+}
+
+// 获取key对应的配置。启用WithProfiles时，基础key返回按profile覆盖后生效的值
 func (c *ConfigCollection) GetValue(key string) *Value {
-	res, ok := c.data[key]
-	if ok {
+	c.dataMux.RLock()
+	defer c.dataMux.RUnlock()
+	if res, ok := c.effective[key]; ok {
+		return res
+	}
+	if res, ok := c.data[key]; ok && (c.client.profiles == nil || isOverlay(key)) {
 		return res
 	}
 	return nil
 }
 
+// GetValue可以获取到的配置
+func (c *ConfigCollection) values() []*Value {
+	c.dataMux.RLock()
+	defer c.dataMux.RUnlock()
+	res := make([]*Value, 0, len(c.data)+len(c.effective))
+	for k, v := range c.data {
+		if c.client.profiles == nil || isOverlay(k) {
+			res = append(res, v)
+		}
+	}
+	for _, v := range c.effective {
+		res = append(res, v)
+	}
+	return res
+}
+
 // 获取配置结合中所有的key-value，以map返回。
 func (c *ConfigCollection) AsMap() map[string]string {
-	res := make(map[string]string)
-	data := c.data // copy to avoid pointer change
-	for k, v := range data {
+	c.dataMux.RLock()
+	values := make(map[string]*Value, len(c.data))
+	for k, v := range c.data {
+		values[k] = v
+	}
+	c.dataMux.RUnlock()
+	res := make(map[string]string, len(values))
+	for k, v := range values { //解析占位符时可能读取本集合，不能持有dataMux
 		res[k] = v.Raw()
 	}
 	return res
 }
 
-func (c *ConfigCollection) AddConfigChangeListener(key string, configChangeListener ConfigChangeListener) {
-	v, ok := c.listeners[key]
-	if !ok {
-		v = make([]ConfigChangeListener, 0, 1)
+// 注册的监听器，按指针移除
+type listenerEntry struct {
+	listener ConfigChangeListener
+}
+
+// 返回去掉e后的新切片，不修改原切片，避免影响正在通知的监听器列表
+func without[T any](entries []*T, e *T) []*T {
+	res := make([]*T, 0, len(entries))
+	for _, x := range entries {
+		if x != e {
+			res = append(res, x)
+		}
+	}
+	return res
+}
+
+// 监听key的变化，返回的函数用于移除该监听器，监听方不再使用时(如Close)应调用，避免泄漏
+func (c *ConfigCollection) AddConfigChangeListener(key string, configChangeListener ConfigChangeListener) (remove func()) {
+	e := &listenerEntry{listener: configChangeListener}
+	c.lisMux.Lock()
+	defer c.lisMux.Unlock()
+	c.listeners[key] = append(c.listeners[key], e)
+	return func() {
+		c.lisMux.Lock()
+		defer c.lisMux.Unlock()
+		if v := without(c.listeners[key], e); len(v) > 0 {
+			c.listeners[key] = v
+		} else {
+			delete(c.listeners, key)
+		}
+	}
+}
+
+// 监听集合中所有key的变化，包括新增及删除的key，返回的函数用于移除该监听器
+func (c *ConfigCollection) AddCollectionChangeListener(configChangeListener ConfigChangeListener) (remove func()) {
+	e := &listenerEntry{listener: configChangeListener}
+	c.lisMux.Lock()
+	defer c.lisMux.Unlock()
+	c.anyLis = append(c.anyLis, e)
+	return func() {
+		c.lisMux.Lock()
+		defer c.lisMux.Unlock()
+		c.anyLis = without(c.anyLis, e)
 	}
-	v = append(v, configChangeListener)
-	c.listeners[key] = v
 }
 
-func (c *ConfigCollection) refreshData(client *gConfHttpClient) {
-	newDataMap := client.listConfigs(c.appId)
+func (c *ConfigCollection) refreshData(ctx context.Context) (err error) {
+	ctx, span := c.client.tracer.Start(ctx, "gconf.refreshData", trace.WithAttributes(attrAppId.String(c.appId)))
+	defer func() { endSpan(span, err) }()
+	c.refreshMux.Lock()
+	defer c.refreshMux.Unlock()
+	newDataMap, err := c.client.source.listConfigs(ctx, c.appId)
+	if err != nil {
+		c.client.logger.Warn("refresh failed", "appId", c.appId, "error", err)
+		c.health.failed(err)
+		c.client.observer.ObserveRefresh(c.appId, 0, err)
+		return err
+	}
+	c.health.refreshed(newDataMap)
+	defer c.markSynced()
 	if len(newDataMap) == 0 {
-		return
+		c.client.observer.ObserveRefresh(c.appId, 0, nil)
+		return nil
 	}
-	dataMap := c.data
+	dataMap := c.data //只有刷新时修改map，刷新已由refreshMux串行化，这里读取无需加锁
+	var dependents map[*Value]string
+	if c.client.interpolation && c.isLoaded() { //变化前记录引用方解析后的值
+		dependents = c.client.dependents(c.appId, changedKeys(dataMap, newDataMap))
+	}
+	keysChanged := 0
 	for key, oldValue := range dataMap {
 		newValue, ok := newDataMap[key]
 		if ok {
-			o := oldValue.value
-			if oldValue.refresh(newValue) {
-				c.fireValueChanged(key, o, newValue)
+			o := oldValue.Raw()
+			if oldValue.removed { //删除后又重新添加
+				oldValue.setRemoved(false)
+				oldValue.refresh(newValue)
+				keysChanged++
+				c.fireRawChanged(key, "", c.resolved(oldValue))
+			} else if oldValue.refresh(newValue) {
+				keysChanged++
+				c.fireRawChanged(key, o, c.resolved(oldValue))
 			}
-		} else { //老的有，但新的没有，先不从缓存里删除，避免程序出错。
-			c.fireValueChanged(key, oldValue.value, "")
+		} else if !oldValue.removed { //老的有，但新的没有，先不从缓存里删除，避免程序出错。
+			oldValue.setRemoved(true)
+			keysChanged++
+			c.fireRawChanged(key, oldValue.Raw(), "")
 		}
 	}
 	for key, newV := range newDataMap {
 		_, ok := dataMap[key]
 		if !ok {
-			dataMap[key] = newValue(key, newV)
+			keysChanged++
+			v := newValue(c, key, newV)
+			c.dataMux.Lock()
+			dataMap[key] = v
+			c.dataMux.Unlock()
+			if c.isLoaded() { //首次加载时还没有监听器
+				c.fireRawChanged(key, "", c.resolved(v))
+			}
 		}
 	}
+	if c.client.profiles != nil {
+		oldAssignments, targetingChanged := c.refreshTargeting()
+		c.refreshEffective()
+		if targetingChanged && c.isLoaded() {
+			c.fireTargetingChanged(oldAssignments, c.Assignments())
+		}
+	}
+	for v, old := range dependents { //被引用的配置变化，引用方解析后的值随之变化
+		if newV := v.collection.resolved(v); newV != old {
+			v.refreshHandler()
+			v.collection.fireValueChanged(v.key, old, newV)
+		}
+	}
+	c.client.observer.ObserveRefresh(c.appId, keysChanged, nil)
+	span.SetAttributes(attrKeysChanged.Int(keysChanged))
+	return nil
 }
 
-func (c *ConfigCollection) fireValueChanged(key, oldValue, newValue string) {
-	log.Printf("valueChanged,appId %s,key %s,oldValue--------->:\n%s\n    newValue--------->:\n%s", c.appId, key, oldValue, newValue)
-	if listeners, ok := c.listeners[key]; ok {
-		for _, listener := range listeners {
-			listener.valueChanged(key, oldValue, newValue)
+// 解析后的值，解析失败时记录日志
+func (c *ConfigCollection) resolved(v *Value) string {
+	res, err := v.Resolve()
+	if err != nil {
+		c.client.logger.Warn("interpolation failed", "appId", c.appId, "key", v.key, "error", err)
+	}
+	return res
+}
+
+// 与newDataMap相比新增、删除或修改的key
+func changedKeys(dataMap map[string]*Value, newDataMap map[string]string) []string {
+	var keys []string
+	for key, v := range dataMap {
+		newV, ok := newDataMap[key]
+		if ok && (v.removed || v.Template() != newV) || !ok && !v.removed {
+			keys = append(keys, key)
+		}
+	}
+	for key := range newDataMap {
+		if _, ok := dataMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys { //覆盖配置变化时，基础key生效的值可能变化
+		if base, _, ok := splitOverlay(key); ok {
+			keys = append(keys, base)
 		}
 	}
-	log.Printf("firedValueChanged,appId %s,key %s", c.appId, key)
+	return keys
+}
+
+// 标记与服务端一致，首次调用时标记为已加载
+func (c *ConfigCollection) markSynced() {
+	c.health.synced()
+	select {
+	case <-c.loaded:
+	default:
+		close(c.loaded)
+	}
+}
+
+func (c *ConfigCollection) isLoaded() bool {
+	select {
+	case <-c.loaded:
+		return true
+	default:
+		return false
+	}
+}
+
+// 启用WithProfiles时，基础key的变化由refreshEffective按生效的值通知
+func (c *ConfigCollection) fireRawChanged(key, oldValue, newValue string) {
+	if c.client.profiles == nil || isOverlay(key) {
+		c.fireValueChanged(key, oldValue, newValue)
+	}
+}
+
+func (c *ConfigCollection) fireValueChanged(key, oldValue, newValue string) {
+	logger := c.client.logger.With("appId", c.appId, "key", key)
+	logger.Info("valueChanged", "summary", summarizeChange(key, oldValue, newValue))
+	if logger.Enabled(context.Background(), slog.LevelDebug) {
+		redactor := c.client.redactor
+		logger.Debug("valueChanged detail", "oldValue", redactor.Redact(key, oldValue), "newValue", redactor.Redact(key, newValue))
+	}
+	c.lisMux.RLock()
+	listeners := append(append([]*listenerEntry(nil), c.listeners[key]...), c.anyLis...)
+	c.lisMux.RUnlock()
+	for _, e := range listeners {
+		c.callListener(e.listener, key, oldValue, newValue)
+	}
+	logger.Debug("firedValueChanged")
+}
+
+// 监听器panic时记录日志并继续，避免中断后台同步
+func (c *ConfigCollection) callListener(listener ConfigChangeListener, key, oldValue, newValue string) {
+	start := time.Now()
+	panicked := true
+	defer func() {
+		if panicked {
+			c.client.logger.Error("listener panic", "appId", c.appId, "key", key, "panic", recover())
+		}
+		c.client.observer.ObserveListener(c.appId, key, time.Since(start), panicked)
+	}()
+	listener.valueChanged(key, oldValue, newValue)
+	panicked = false
 }
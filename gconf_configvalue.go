@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -26,39 +27,94 @@ func (vh *valueHandler) refresh(value string) error {
 }
 
 type Value struct {
+	collection   *ConfigCollection
 	key          string
 	value        string
 	fileType     int
 	valueHandler *valueHandler
+	mux          sync.RWMutex // 保护value，后台刷新与业务读取并发
+	removed      bool         // 服务端已删除，缓存中保留最后的值
+	origin       string       // 启用WithProfiles时，基础key生效的配置key
 }
 
-func newValue(key, value string) *Value {
-	fileType := text
-	if strings.HasSuffix(key, ".properties") {
-		fileType = properties
-	} else if strings.HasSuffix(key, ".json") {
-		fileType = jsons
-	}
+func newValue(collection *ConfigCollection, key, value string) *Value {
 	return &Value{
+		collection:   collection,
 		key:          key,
 		value:        value,
-		fileType:     fileType,
+		fileType:     fileTypeOf(key),
 		valueHandler: nil,
 	}
 }
 
+// 根据key后缀判断文件类型
+func fileTypeOf(key string) int {
+	if strings.HasSuffix(key, ".properties") {
+		return properties
+	} else if strings.HasSuffix(key, ".json") {
+		return jsons
+	}
+	return text
+}
+
+// 配置的值，开启WithInterpolation时为解析占位符后的值，无法解析的占位符原样保留
 func (v *Value) Raw() string {
+	res, _ := v.Resolve()
+	return res
+}
+
+// 未解析占位符的原始值
+func (v *Value) Template() string {
 	if v == nil {
 		return ""
 	}
+	v.mux.RLock()
+	defer v.mux.RUnlock()
 	return v.value
 }
 
+// 解析占位符后的值，引用的配置不存在或循环引用时返回错误。未开启WithInterpolation时与Template相同
+func (v *Value) Resolve() (string, error) {
+	s := v.Template()
+	if s == "" || v.collection == nil || !v.collection.client.interpolation {
+		return s, nil
+	}
+	return v.collection.client.interpolate(ref{appId: v.collection.appId, key: v.key}, s, nil)
+}
+
+// 值来自的配置key。启用WithProfiles时，基础key的值可能来自覆盖配置，如timeout@prod-sh.properties
+func (v *Value) Origin() string {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	if v.origin != "" {
+		return v.origin
+	}
+	return v.key
+}
+
+func (v *Value) setOrigin(origin string) {
+	v.mux.Lock()
+	v.origin = origin
+	v.mux.Unlock()
+}
+
+func (v *Value) isRemoved() bool {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	return v.removed
+}
+
+func (v *Value) setRemoved(removed bool) {
+	v.mux.Lock()
+	v.removed = removed
+	v.mux.Unlock()
+}
+
 func (v *Value) AsProperties() map[string]string {
 	if v.fileType != properties {
 		panic("unsupported")
 	}
-	return readMapFromProp(v.Raw())
+	return ParseProperties(v.Raw())
 }
 
 func (v *Value) AsJson() map[string]any {
@@ -75,14 +131,22 @@ func (v *Value) FileType() int {
 }
 
 func (v *Value) refresh(newValue string) bool {
+	v.mux.Lock()
 	if v.value == newValue {
+		v.mux.Unlock()
 		return false
 	}
 	v.value = newValue
+	v.mux.Unlock()
+	v.refreshHandler()
+	return true
+}
+
+// 使用解析后的值更新注册的bean
+func (v *Value) refreshHandler() {
 	if v.valueHandler != nil {
-		v.valueHandler.refresh(newValue)
+		v.valueHandler.refresh(v.Raw())
 	}
-	return true
 }
 
 // 注册一个bean，会自动更新
@@ -97,7 +161,11 @@ func (v *Value) Register(x any) error {
 	} else {
 		panic("unsupported filed type")
 	}
-	return v.valueHandler.refresh(v.value)
+	value, err := v.Resolve()
+	if err != nil {
+		return err
+	}
+	return v.valueHandler.refresh(value)
 }
 
 func jsonFunc(value string, cp any) error {
@@ -105,7 +173,7 @@ func jsonFunc(value string, cp any) error {
 }
 
 func propFunc(value string, cp any) error {
-	data := readMapFromProp(value)
+	data := ParseProperties(value)
 	if len(data) == 0 {
 		return nil
 	}
@@ -209,7 +277,9 @@ func setFieldValue(filed reflect.Value, value string) {
 	}
 
 }
-func readMapFromProp(value string) map[string]string {
+
+// 按gconf的规则解析properties：忽略#及!开头的注释行，每行按第一个=分隔key及value，key及value不去除空白
+func ParseProperties(value string) map[string]string {
 	var (
 		part   []byte
 		prefix bool
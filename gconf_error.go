@@ -0,0 +1,61 @@
+package gconf
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNotInitialized = errors.New("gconf: client not initialized")
+	ErrNotFound       = errors.New("gconf: not found")
+)
+
+// 配置错误，说明出错的集合、key及字段，Err为原因
+type ConfigError struct {
+	AppId string
+	Key   string
+	Field string // 出错的字段，与字段无关时为空
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	b.WriteString("gconf: config")
+	for _, kv := range [][2]string{{"app", e.AppId}, {"key", e.Key}, {"field", e.Field}} {
+		if kv[1] != "" {
+			b.WriteString(" " + kv[0] + " " + kv[1])
+		}
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// 返回key解析占位符后的值。key不存在时返回包装ErrNotFound的*ConfigError
+func (c *ConfigCollection) Lookup(key string) (string, error) {
+	v := c.GetValue(key)
+	if v == nil || v.isRemoved() {
+		return "", &ConfigError{AppId: c.appId, Key: key, Err: ErrNotFound}
+	}
+	res, err := v.Resolve()
+	if err != nil {
+		return "", &ConfigError{AppId: c.appId, Key: key, Err: err}
+	}
+	return res, nil
+}
+
+// 返回当前应用中key解析占位符后的值，未调用Init时返回ErrNotInitialized
+func Lookup(key string) (string, error) {
+	if std == nil {
+		return "", ErrNotInitialized
+	}
+	c := std.GetCurrentConfigCollection()
+	if c == nil {
+		return "", &ConfigError{AppId: std.appId, Key: key, Err: ErrNotFound}
+	}
+	return c.Lookup(key)
+}
@@ -0,0 +1,35 @@
+package gconf_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+func TestLookup(t *testing.T) {
+	c := gconftest.NewClient(t, "app", gconf.WithInterpolation())
+	c.Provider.SetCollection("app", map[string]string{"a": "1", "b": "${a}", "c": "${nope}"})
+	collection := c.GetCurrentConfigCollection()
+
+	if v, err := collection.Lookup("b"); err != nil || v != "1" {
+		t.Errorf("Lookup(b) = %q, %v", v, err)
+	}
+	_, err := collection.Lookup("missing")
+	var ce *gconf.ConfigError
+	if !errors.As(err, &ce) || !errors.Is(err, gconf.ErrNotFound) || ce.AppId != "app" || ce.Key != "missing" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err.Error() != "gconf: config app app key missing: gconf: not found" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if _, err := collection.Lookup("c"); !errors.Is(err, gconf.ErrUnresolved) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	err = &gconf.ConfigError{Key: "redis.json", Field: "type", Err: errors.New("unsupported")}
+	if err.Error() != "gconf: config key redis.json field type: unsupported" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
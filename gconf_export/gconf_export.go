@@ -0,0 +1,354 @@
+// Package gconf_export 将配置集合导出为dotenv、JSON、YAML或Kubernetes ConfigMap/Secret，
+// 并可将导出的内容导入内存配置源，用于迁移及调试。
+//
+//	gconf_export.Export(os.Stdout, gconf.GetCurrentConfigCollection(), gconf_export.Kubernetes,
+//		gconf_export.WithNamespace("default"))
+package gconf_export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/guanaitong/gconf-go-client"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	Dotenv     Format = "env" // key转换为环境变量名(见EnvName)，原来的key写在前一行的注释中，读取时还原
+	JSON       Format = "json"
+	YAML       Format = "yaml"
+	Kubernetes Format = "kubernetes" // ConfigMap及Secret两个文档，敏感配置放入Secret
+)
+
+var Formats = []Format{Dotenv, JSON, YAML, Kubernetes}
+
+// 解析格式名，k8s为kubernetes的别名
+func ParseFormat(s string) (Format, error) {
+	if s == "k8s" {
+		return Kubernetes, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("gconf_export: unsupported format %q", s)
+}
+
+type options struct {
+	name      string
+	namespace string
+	redactor  *gconf.Redactor
+}
+
+type Option func(*options)
+
+// Kubernetes资源名，默认由appId转换
+func WithName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// Kubernetes资源的namespace，默认不设置
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// 判断配置是否放入Secret的规则，默认使用gconf.DefaultRedactKeys及gconf.DefaultRedactValues，为nil时使用默认规则
+func WithRedactor(r *gconf.Redactor) Option {
+	return func(o *options) {
+		if r != nil {
+			o.redactor = r
+		}
+	}
+}
+
+// 导出配置集合当前的内容
+func Export(w io.Writer, c *gconf.ConfigCollection, f Format, opts ...Option) error {
+	return Write(w, c.AppId(), c.AsMap(), f, opts...)
+}
+
+// 按格式输出配置，appId用于Kubernetes资源名
+func Write(w io.Writer, appId string, data map[string]string, f Format, opts ...Option) error {
+	o := &options{redactor: gconf.NewRedactor(gconf.DefaultRedactKeys, gconf.DefaultRedactValues)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	switch f {
+	case Dotenv:
+		return writeDotenv(w, appId, data)
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case YAML:
+		return writeYaml(w, data)
+	case Kubernetes:
+		return writeKubernetes(w, appId, data, o)
+	}
+	return fmt.Errorf("gconf_export: unsupported format %q", f)
+}
+
+func writeDotenv(w io.Writer, appId string, data map[string]string) error {
+	names := make(map[string]string, len(data)) // 环境变量名 -> key
+	for k := range data {
+		name := EnvName(k)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("gconf_export: keys %q and %q of %s map to the same env name %s", other, k, appId, name)
+		}
+		names[name] = k
+	}
+	bw := bufio.NewWriter(w)
+	for _, name := range sortedKeys(names) {
+		k := names[name]
+		if strings.ContainsAny(k, "\r\n") {
+			return fmt.Errorf("gconf_export: key %q of %s contains a line break", k, appId)
+		}
+		if k != name {
+			fmt.Fprintf(bw, "%s%s\n", dotenvKeyComment, k)
+		}
+		fmt.Fprintf(bw, "%s=%s\n", name, quoteEnv(data[k]))
+	}
+	return bw.Flush()
+}
+
+// 环境变量名与key不同时，在变量前一行记录原来的key
+const dotenvKeyComment = "# gconf-key: "
+
+// 转换为可移植的环境变量名([A-Z0-9_]+)：字母转为大写，其他字符替换为_，数字开头时加前缀_。
+// 如datasource.json转换为DATASOURCE_JSON
+func EnvName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 || b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+var envEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", `\$`)
+
+// 按dotenv的双引号规则转义
+func quoteEnv(v string) string {
+	return `"` + envEscaper.Replace(v) + `"`
+}
+
+func writeYaml(w io.Writer, docs ...any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+type metadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type manifest struct {
+	ApiVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   metadata          `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+}
+
+// key或值敏感，以及properties/json中有敏感字段的配置放入Secret，其余放入ConfigMap
+func writeKubernetes(w io.Writer, appId string, data map[string]string, o *options) error {
+	name := o.name
+	if name == "" {
+		name = resourceName(appId)
+	}
+	configMap := map[string]string{}
+	secret := map[string]string{}
+	for k, v := range data {
+		if !validDataKey.MatchString(k) {
+			return fmt.Errorf("gconf_export: key %q of %s is not a valid ConfigMap key", k, appId)
+		}
+		if o.redactor.ContainsSensitive(k, v) {
+			secret[k] = base64.StdEncoding.EncodeToString([]byte(v))
+		} else {
+			configMap[k] = v
+		}
+	}
+	meta := metadata{Name: name, Namespace: o.namespace}
+	docs := []any{manifest{ApiVersion: "v1", Kind: "ConfigMap", Metadata: meta, Data: configMap}}
+	if len(secret) > 0 {
+		docs = append(docs, manifest{ApiVersion: "v1", Kind: "Secret", Metadata: meta, Type: "Opaque", Data: secret})
+	}
+	return writeYaml(w, docs...)
+}
+
+var (
+	validDataKey   = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	invalidNameRun = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// 转换为符合RFC 1123的资源名
+func resourceName(appId string) string {
+	name := strings.Trim(invalidNameRun.ReplaceAllString(strings.ToLower(appId), "-"), "-")
+	if len(name) > 253 {
+		name = strings.TrimRight(name[:253], "-")
+	}
+	if name == "" {
+		name = "gconf"
+	}
+	return name
+}
+
+// 读取Write输出的内容。Kubernetes格式合并所有ConfigMap及Secret的data/stringData
+func Read(r io.Reader, f Format) (map[string]string, error) {
+	switch f {
+	case Dotenv:
+		return readDotenv(r)
+	case JSON:
+		data := map[string]string{}
+		if err := json.NewDecoder(r).Decode(&data); err != nil {
+			return nil, fmt.Errorf("gconf_export: decode json: %w", err)
+		}
+		return data, nil
+	case YAML:
+		data := map[string]string{}
+		if err := yaml.NewDecoder(r).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("gconf_export: decode yaml: %w", err)
+		}
+		return data, nil
+	case Kubernetes:
+		return readKubernetes(r)
+	}
+	return nil, fmt.Errorf("gconf_export: unsupported format %q", f)
+}
+
+// 读取配置并替换内存配置源中appId对应的集合
+func Import(p *gconf.MemoryProvider, appId string, r io.Reader, f Format) error {
+	data, err := Read(r, f)
+	if err != nil {
+		return err
+	}
+	p.SetCollection(appId, data)
+	return nil
+}
+
+func readDotenv(r io.Reader) (map[string]string, error) {
+	data := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	key := "" // 前一行注释记录的key
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if k, ok := strings.CutPrefix(line, strings.TrimSpace(dotenvKeyComment)); ok {
+			key = strings.TrimSpace(k)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("gconf_export: line %d: missing '='", n)
+		}
+		value, err := unquoteEnv(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("gconf_export: line %d: %w", n, err)
+		}
+		if key == "" {
+			key = strings.TrimSpace(k)
+		}
+		data[key] = value
+		key = ""
+	}
+	return data, scanner.Err()
+}
+
+func unquoteEnv(v string) (string, error) {
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1], nil
+	}
+	if len(v) == 0 || v[0] != '"' {
+		return v, nil
+	}
+	if len(v) < 2 || v[len(v)-1] != '"' {
+		return "", errors.New("unterminated quoted value")
+	}
+	var b strings.Builder
+	for i := 1; i < len(v)-1; i++ {
+		c := v[i]
+		if c != '\\' || i == len(v)-2 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch v[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(v[i])
+		}
+	}
+	return b.String(), nil
+}
+
+func readKubernetes(r io.Reader) (map[string]string, error) {
+	data := map[string]string{}
+	dec := yaml.NewDecoder(r)
+	for {
+		var m manifest
+		if err := dec.Decode(&m); errors.Is(err, io.EOF) {
+			return data, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("gconf_export: decode manifest: %w", err)
+		}
+		switch m.Kind {
+		case "ConfigMap":
+			for k, v := range m.Data {
+				data[k] = v
+			}
+		case "Secret":
+			for k, v := range m.Data {
+				bs, err := base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					return nil, fmt.Errorf("gconf_export: decode secret %s key %s: %w", m.Metadata.Name, k, err)
+				}
+				data[k] = string(bs)
+			}
+		default:
+			continue
+		}
+		for k, v := range m.StringData {
+			data[k] = v
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,139 @@
+package gconf_export
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+var testData = map[string]string{
+	"deny.properties": "path=/tmp/impower\ncmd=date122",
+	"datasource.json": `{"username":"app","password":"s3cr3t"}`,
+	"db.password":     "p@ss\"word$1\\",
+	"banner":          "line1\r\nline2 # not a comment",
+	"empty":           "",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, f := range Formats {
+		t.Run(string(f), func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			if err := Write(buf, "userdoor", testData, f); err != nil {
+				t.Fatal(err)
+			}
+			data, err := Read(buf, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(data, testData) {
+				t.Fatalf("round trip mismatch:\n%v\n%v", data, testData)
+			}
+		})
+	}
+}
+
+func TestKubernetesRoutesSecrets(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, "User_Door", testData, Kubernetes, WithNamespace("prod")); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	configMap, secret, ok := strings.Cut(out, "---\n")
+	if !ok {
+		t.Fatalf("expected two documents: %s", out)
+	}
+	for _, want := range []string{"kind: ConfigMap", "name: user-door", "namespace: prod", "deny.properties:", "banner:"} {
+		if !strings.Contains(configMap, want) {
+			t.Errorf("ConfigMap does not contain %q:\n%s", want, configMap)
+		}
+	}
+	for _, want := range []string{"kind: Secret", "type: Opaque", "db.password:", "datasource.json:"} {
+		if !strings.Contains(secret, want) {
+			t.Errorf("Secret does not contain %q:\n%s", want, secret)
+		}
+	}
+	if strings.Contains(out, "s3cr3t") {
+		t.Errorf("secret value leaked in plain text:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := Write(buf, "app", map[string]string{"a": "1", "pretty.json": "{\n  \"z\": 1,\n  \"a\": \"x\"\n}"}, Kubernetes); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Secret") {
+		t.Errorf("unexpected Secret without sensitive keys or fields:\n%s", buf.String())
+	}
+	if err := Write(buf, "app", map[string]string{"a b": "1"}, Kubernetes); err == nil {
+		t.Error("expected error for invalid ConfigMap key")
+	}
+}
+
+func TestDotenvKeys(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, "app", map[string]string{"datasource.json": "x", "9lives": "y", "redis-config.json": "z"}, Dotenv); err != nil {
+		t.Fatal(err)
+	}
+	want := "# gconf-key: datasource.json\nDATASOURCE_JSON=\"x\"\n" +
+		"# gconf-key: redis-config.json\nREDIS_CONFIG_JSON=\"z\"\n" +
+		"# gconf-key: 9lives\n_9LIVES=\"y\"\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+	data, err := Read(buf, Dotenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"datasource.json": "x", "9lives": "y", "redis-config.json": "z"}; !reflect.DeepEqual(data, want) {
+		t.Fatalf("original keys not restored: %v", data)
+	}
+	if err := Write(buf, "app", map[string]string{"a.b": "1", "a-b": "2"}, Dotenv); err == nil {
+		t.Error("expected error for keys mapping to the same env name")
+	}
+	if err := Write(buf, "app", map[string]string{"a\nb": "1"}, Dotenv); err == nil {
+		t.Error("expected error for key with a line break")
+	}
+}
+
+func TestReadDotenv(t *testing.T) {
+	data, err := Read(strings.NewReader("# comment\n\nexport A=1\nB = 'x \\n y'\nC=\"a\\nb\"\n"), Dotenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"A": "1", "B": `x \n y`, "C": "a\nb"}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("got %v, want %v", data, want)
+	}
+	if _, err := Read(strings.NewReader("A=\"open\n"), Dotenv); err == nil {
+		t.Error("expected error for unterminated value")
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	p := gconf.NewMemoryProvider()
+	p.SetCollection("src", testData)
+	c := gconf.NewClient("src", gconf.WithMemoryProvider(p))
+	defer c.Close()
+	if err := c.WaitReady(context.Background(), "src"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range Formats {
+		buf := new(bytes.Buffer)
+		if err := Export(buf, c.GetConfigCollection("src"), f); err != nil {
+			t.Fatal(err)
+		}
+		dst := "dst-" + string(f)
+		if err := Import(p, dst, buf, f); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c.GetConfigCollection(dst).AsMap(), testData) {
+			t.Fatalf("%s: imported collection mismatch: %v", f, c.GetConfigCollection(dst).AsMap())
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package gconf_flags 基于配置集合中的flags.json实现功能开关，支持布尔及多值开关、
+// 按用户key稳定分桶的百分比灰度、白名单及黑名单，配置变化时自动生效。
+//
+//	f, err := gconf_flags.New(gconf.GetCurrentConfigCollection(), gconf_flags.WithObserver(metrics))
+//	defer f.Close()
+//	if f.IsEnabled(ctx, "new-checkout", gconf_flags.EvalContext{Key: userId}) {
+//		...
+//	}
+//
+// flags.json的格式：
+//
+//	{
+//	  "flags": {
+//	    "new-checkout": {"enabled": true, "percentage": 10, "allow": ["u1"], "deny": ["u2"]},
+//	    "button-color": {
+//	      "enabled": true,
+//	      "variants": {"blue": "#00f", "green": "#0f0"},
+//	      "defaultVariant": "blue",
+//	      "rollout": [{"variant": "blue", "weight": 50}, {"variant": "green", "weight": 50}]
+//	    }
+//	  }
+//	}
+package gconf_flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+// 默认的开关配置key
+const DefaultKey = "flags.json"
+
+const (
+	On  = "on"
+	Off = "off"
+)
+
+// 评估结果的原因
+const (
+	ReasonOverride    = "override" // 通过WithOverride指定
+	ReasonMissing     = "missing"  // 开关不存在
+	ReasonDisabled    = "disabled" // 开关关闭
+	ReasonDeny        = "deny"
+	ReasonAllow       = "allow"
+	ReasonRollout     = "rollout"     // 按分桶命中
+	ReasonNoKey       = "no_key"      // 需要分桶但未提供用户key
+	ReasonFallthrough = "fallthrough" // 开启且无需分桶
+)
+
+// 多值开关的流量分配
+type Split struct {
+	Variant string  `json:"variant"`
+	Weight  float64 `json:"weight"`
+}
+
+// 单个开关。未设置Variants时为布尔开关，变体为on(true)及off(false)
+type Flag struct {
+	Enabled        bool           `json:"enabled"`
+	Variants       map[string]any `json:"variants,omitempty"`
+	DefaultVariant string         `json:"defaultVariant,omitempty"` // 关闭、黑名单及未命中灰度时使用，默认off
+	Variant        string         `json:"variant,omitempty"`        // 开启及白名单时使用，默认on
+	Percentage     *float64       `json:"percentage,omitempty"`     // 0-100，按用户key分桶，命中时使用Variant
+	Rollout        []Split        `json:"rollout,omitempty"`        // 按权重分配变体，优先于Percentage
+	Allow          []string       `json:"allow,omitempty"`
+	Deny           []string       `json:"deny,omitempty"`
+	Salt           string         `json:"salt,omitempty"` // 分桶的盐，默认为开关名，修改后重新分桶
+}
+
+type config struct {
+	Flags map[string]*Flag `json:"flags"`
+}
+
+// 评估的上下文
+type EvalContext struct {
+	Key string // 用户key，用于黑白名单及分桶
+}
+
+// 评估结果
+type Evaluation struct {
+	Flag    string
+	Variant string
+	Value   any
+	Reason  string
+}
+
+// 评估结果的观察者，通过WithObserver注册，见gconf_metrics。
+// 每次评估时同步调用，实现需并发安全且不要阻塞
+type Observer interface {
+	ObserveEvaluation(e Evaluation)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveEvaluation(e Evaluation) {}
+
+type options struct {
+	key      string
+	logger   *slog.Logger
+	observer Observer
+}
+
+type Option func(*options)
+
+// 指定开关配置的key，默认为flags.json
+func WithKey(key string) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
+
+// 指定日志输出，默认使用slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 注册评估结果的观察者，用于统计各开关的评估次数
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		o.observer = observer
+	}
+}
+
+// 功能开关
+type Flags struct {
+	key      string
+	logger   *slog.Logger
+	config   atomic.Pointer[config]
+	observer Observer
+
+	removeListener func()
+}
+
+// 读取集合中的开关配置并监听变化。配置无法解析时返回错误，之后的变化无法解析时保留原配置
+func New(c *gconf.ConfigCollection, opts ...Option) (*Flags, error) {
+	o := &options{key: DefaultKey, observer: nopObserver{}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	f := &Flags{
+		key:      o.key,
+		logger:   o.logger.With("component", "gconf_flags", "appId", c.AppId(), "key", o.key),
+		observer: o.observer,
+	}
+	cfg, err := parse(c.GetValue(o.key).Raw())
+	if err != nil {
+		return nil, fmt.Errorf("gconf_flags: parse %s of %s: %w", o.key, c.AppId(), err)
+	}
+	f.config.Store(cfg)
+	f.removeListener = c.AddConfigChangeListener(o.key, gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		cfg, err := parse(newValue)
+		if err != nil {
+			f.logger.Warn("invalid flags, keep previous", "error", err)
+			return
+		}
+		f.config.Store(cfg)
+		f.logger.Info("flags reloaded", "flags", len(cfg.Flags))
+	}))
+	return f, nil
+}
+
+// 不再响应配置变化，之后按关闭时的配置评估
+func (f *Flags) Close() {
+	f.removeListener()
+}
+
+func parse(value string) (*config, error) {
+	cfg := &config{}
+	if value == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(value), cfg); err != nil {
+		return nil, err
+	}
+	for name, flag := range cfg.Flags {
+		if flag == nil {
+			return nil, fmt.Errorf("flag %s is null", name)
+		}
+		if flag.Variants == nil {
+			flag.Variants = map[string]any{On: true, Off: false}
+		}
+		if flag.Variant == "" {
+			flag.Variant = On
+		}
+		if flag.DefaultVariant == "" {
+			flag.DefaultVariant = Off
+		}
+		for _, v := range append([]string{flag.Variant, flag.DefaultVariant}, variantsOf(flag.Rollout)...) {
+			if _, ok := flag.Variants[v]; !ok {
+				return nil, fmt.Errorf("flag %s: unknown variant %q", name, v)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func variantsOf(splits []Split) []string {
+	res := make([]string, len(splits))
+	for i, s := range splits {
+		res[i] = s.Variant
+	}
+	return res
+}
+
+type overrideKey struct{}
+
+// 在ctx中强制指定开关的变体，用于测试或按请求调试
+func WithOverride(ctx context.Context, flag, variant string) context.Context {
+	overrides, _ := ctx.Value(overrideKey{}).(map[string]string)
+	m := make(map[string]string, len(overrides)+1)
+	for k, v := range overrides {
+		m[k] = v
+	}
+	m[flag] = variant
+	return context.WithValue(ctx, overrideKey{}, m)
+}
+
+// 开关的值为true时返回true。多值开关请使用Evaluate
+func (f *Flags) IsEnabled(ctx context.Context, name string, evalCtx EvalContext) bool {
+	v, _ := f.Evaluate(ctx, name, evalCtx).Value.(bool)
+	return v
+}
+
+// 评估开关，返回命中的变体、取值及原因
+func (f *Flags) Evaluate(ctx context.Context, name string, evalCtx EvalContext) Evaluation {
+	res := f.evaluate(ctx, name, evalCtx)
+	f.observer.ObserveEvaluation(res)
+	return res
+}
+
+func (f *Flags) evaluate(ctx context.Context, name string, evalCtx EvalContext) Evaluation {
+	flag, ok := f.config.Load().Flags[name]
+	if !ok {
+		return Evaluation{Flag: name, Value: false, Reason: ReasonMissing}
+	}
+	serve := func(variant, reason string) Evaluation {
+		return Evaluation{Flag: name, Variant: variant, Value: flag.Variants[variant], Reason: reason}
+	}
+	if overrides, _ := ctx.Value(overrideKey{}).(map[string]string); overrides != nil {
+		if v, ok := overrides[name]; ok {
+			if _, known := flag.Variants[v]; known {
+				return serve(v, ReasonOverride)
+			}
+		}
+	}
+	switch {
+	case !flag.Enabled:
+		return serve(flag.DefaultVariant, ReasonDisabled)
+	case evalCtx.Key != "" && slices.Contains(flag.Deny, evalCtx.Key):
+		return serve(flag.DefaultVariant, ReasonDeny)
+	case evalCtx.Key != "" && slices.Contains(flag.Allow, evalCtx.Key):
+		return serve(flag.Variant, ReasonAllow)
+	case len(flag.Rollout) == 0 && flag.Percentage == nil:
+		return serve(flag.Variant, ReasonFallthrough)
+	case evalCtx.Key == "":
+		return serve(flag.DefaultVariant, ReasonNoKey)
+	}
+
+	salt := flag.Salt
+	if salt == "" {
+		salt = name
+	}
+	b := gconf.Bucket(salt, evalCtx.Key)
+	if len(flag.Rollout) == 0 {
+		if b < *flag.Percentage {
+			return serve(flag.Variant, ReasonRollout)
+		}
+		return serve(flag.DefaultVariant, ReasonRollout)
+	}
+	total := 0.0
+	for _, s := range flag.Rollout {
+		total += s.Weight
+	}
+	acc := 0.0
+	for _, s := range flag.Rollout {
+		acc += s.Weight
+		if total > 0 && b < acc/total*100 {
+			return serve(s.Variant, ReasonRollout)
+		}
+	}
+	return serve(flag.DefaultVariant, ReasonRollout)
+}
@@ -0,0 +1,156 @@
+package gconf_flags
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+const testFlags = `{"flags": {
+	"checkout": {"enabled": true, "percentage": 20, "allow": ["vip"], "deny": ["banned"]},
+	"off": {"enabled": false, "allow": ["vip"]},
+	"all": {"enabled": true},
+	"color": {
+		"enabled": true,
+		"variants": {"blue": "#00f", "green": "#0f0", "red": "#f00"},
+		"defaultVariant": "blue",
+		"variant": "red",
+		"rollout": [{"variant": "blue", "weight": 1}, {"variant": "green", "weight": 3}]
+	}
+}}`
+
+// 按开关、变体及原因统计评估次数
+type countingObserver struct {
+	mux    sync.Mutex
+	counts map[Evaluation]int
+}
+
+func (o *countingObserver) ObserveEvaluation(e Evaluation) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.counts[Evaluation{Flag: e.Flag, Variant: e.Variant, Reason: e.Reason}]++
+}
+
+func newFlags(t *testing.T, value string, opts ...Option) (*gconftest.Client, *Flags) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", DefaultKey, value)
+	f, err := New(c.GetCurrentConfigCollection(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, f
+}
+
+func TestEvaluate(t *testing.T) {
+	_, f := newFlags(t, testFlags)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		flag, key, variant, reason string
+	}{
+		{"missing", "u", "", ReasonMissing},
+		{"off", "vip", Off, ReasonDisabled},
+		{"all", "", On, ReasonFallthrough},
+		{"checkout", "banned", Off, ReasonDeny},
+		{"checkout", "vip", On, ReasonAllow},
+		{"checkout", "", Off, ReasonNoKey},
+		{"color", "vip", "blue", ReasonRollout},
+	} {
+		got := f.Evaluate(ctx, tc.flag, EvalContext{Key: tc.key})
+		if tc.flag == "color" {
+			if got.Reason != tc.reason || (got.Variant != "blue" && got.Variant != "green") {
+				t.Errorf("%s: unexpected %+v", tc.flag, got)
+			}
+			continue
+		}
+		if got.Variant != tc.variant || got.Reason != tc.reason {
+			t.Errorf("Evaluate(%s, %q) = %+v, want %s/%s", tc.flag, tc.key, got, tc.variant, tc.reason)
+		}
+	}
+	if f.IsEnabled(ctx, "missing", EvalContext{}) || !f.IsEnabled(ctx, "all", EvalContext{}) {
+		t.Error("unexpected IsEnabled result")
+	}
+	if !f.IsEnabled(WithOverride(ctx, "off", On), "off", EvalContext{}) {
+		t.Error("override not applied")
+	}
+	if got := f.Evaluate(ctx, "color", EvalContext{Key: "u"}); got.Value != "#00f" && got.Value != "#0f0" {
+		t.Errorf("unexpected value %v", got.Value)
+	}
+}
+
+func TestRolloutDistribution(t *testing.T) {
+	observer := &countingObserver{counts: map[Evaluation]int{}}
+	_, f := newFlags(t, testFlags, WithObserver(observer))
+	ctx := context.Background()
+	enabled, green := 0, 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		on := f.IsEnabled(ctx, "checkout", EvalContext{Key: key})
+		if on != f.IsEnabled(ctx, "checkout", EvalContext{Key: key}) {
+			t.Fatal("bucketing is not sticky")
+		}
+		if on != (gconf.Bucket("checkout", key) < 20) {
+			t.Fatal("flags should bucket users like gconf.Bucket")
+		}
+		if on {
+			enabled++
+		}
+		if f.Evaluate(ctx, "color", EvalContext{Key: key}).Variant == "green" {
+			green++
+		}
+	}
+	if enabled < 1800 || enabled > 2200 {
+		t.Errorf("expected about 20%% enabled, got %d", enabled)
+	}
+	if green < 7200 || green > 7800 {
+		t.Errorf("expected about 75%% green, got %d", green)
+	}
+	if got := observer.counts[Evaluation{Flag: "checkout", Variant: On, Reason: ReasonRollout}]; got != enabled*2 {
+		t.Errorf("unexpected evaluation count %v", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	c, f := newFlags(t, `{"flags": {"a": {"enabled": false}}}`)
+	ctx := context.Background()
+	if f.IsEnabled(ctx, "a", EvalContext{}) {
+		t.Fatal("flag should be disabled")
+	}
+	c.SetValue(t, "app", DefaultKey, `{"flags": {"a": {"enabled": true}}}`)
+	if !f.IsEnabled(ctx, "a", EvalContext{}) {
+		t.Fatal("flag not reloaded")
+	}
+	c.SetValue(t, "app", DefaultKey, `{"flags": {"a": {"enabled": true, "variant": "nope"}}}`)
+	if !f.IsEnabled(ctx, "a", EvalContext{}) {
+		t.Fatal("invalid flags should keep the previous config")
+	}
+	c.SetValue(t, "app", "other", "x") //删除集合中最后一个key时不会通知
+	c.DeleteValue(t, "app", DefaultKey)
+	if f.IsEnabled(ctx, "a", EvalContext{}) {
+		t.Fatal("flags should be empty after the key is deleted")
+	}
+}
+
+func TestClose(t *testing.T) {
+	c, f := newFlags(t, `{"flags": {"a": {"enabled": false}}}`)
+	f.Close()
+	f.Close()
+	c.SetValue(t, "app", DefaultKey, `{"flags": {"a": {"enabled": true}}}`)
+	if f.IsEnabled(context.Background(), "a", EvalContext{}) {
+		t.Fatal("closed flags should not reload")
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", DefaultKey, `{"flags": `)
+	if _, err := New(c.GetCurrentConfigCollection()); err == nil {
+		t.Fatal("expected error for invalid flags")
+	}
+	if _, err := New(c.GetCurrentConfigCollection(), WithKey("other.json")); err != nil {
+		t.Fatalf("missing key should yield empty flags: %v", err)
+	}
+}
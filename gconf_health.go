@@ -0,0 +1,166 @@
+package gconf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 配置集合的健康状态
+type CollectionHealth struct {
+	AppId               string    `json:"appId"`
+	Loaded              bool      `json:"loaded"`
+	Hash                string    `json:"hash"` // 最近一次从服务端加载的配置内容摘要
+	Keys                int       `json:"keys"`
+	LastRefresh         time.Time `json:"lastRefresh"`      // 最近一次成功拉取配置的时间
+	LastWatchSuccess    time.Time `json:"lastWatchSuccess"` // 最近一次watch成功返回的时间
+	LastSync            time.Time `json:"lastSync"`         // 最近一次确认与服务端一致的时间
+	StaleSeconds        float64   `json:"staleSeconds"`     // 距LastSync的秒数，未加载时为0
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastErrorTime       time.Time `json:"lastErrorTime"`
+}
+
+// 客户端健康状态
+type Health struct {
+	Healthy     bool               `json:"healthy"`
+	Collections []CollectionHealth `json:"collections"`
+	Endpoints   []EndpointStatus   `json:"endpoints"`
+}
+
+type collectionHealth struct {
+	mux                 sync.Mutex
+	hash                string
+	keys                int
+	lastRefresh         time.Time
+	lastWatchSuccess    time.Time
+	lastSync            time.Time
+	consecutiveFailures int
+	lastError           error
+	lastErrorTime       time.Time
+}
+
+func (h *collectionHealth) refreshed(data map[string]string) {
+	hash := hashConfigs(data)
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.hash = hash
+	h.keys = len(data)
+	h.lastRefresh = time.Now()
+	h.consecutiveFailures = 0
+}
+
+func (h *collectionHealth) watched() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.lastWatchSuccess = time.Now()
+	h.consecutiveFailures = 0
+}
+
+func (h *collectionHealth) synced() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.lastSync = time.Now()
+}
+
+func (h *collectionHealth) failed(err error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.consecutiveFailures++
+	h.lastError = err
+	h.lastErrorTime = time.Now()
+}
+
+func (h *collectionHealth) snapshot() CollectionHealth {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	res := CollectionHealth{
+		Hash:                h.hash,
+		Keys:                h.keys,
+		LastRefresh:         h.lastRefresh,
+		LastWatchSuccess:    h.lastWatchSuccess,
+		LastSync:            h.lastSync,
+		ConsecutiveFailures: h.consecutiveFailures,
+		LastErrorTime:       h.lastErrorTime,
+	}
+	if h.lastError != nil {
+		res.LastError = h.lastError.Error()
+	}
+	return res
+}
+
+// 按key排序后计算sha256，用于判断各实例加载的配置是否一致
+func hashConfigs(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(data[k]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 返回各配置集合的刷新情况及服务地址状态。
+// 配置集合均已加载且没有连续失败时Healthy为true
+func (c *Client) Health() Health {
+	res := Health{
+		Healthy:     true,
+		Collections: []CollectionHealth{},
+		Endpoints:   c.Endpoints(),
+	}
+	now := time.Now()
+	for _, collection := range c.collections() {
+		h := collection.health.snapshot()
+		h.AppId = collection.appId
+		h.Loaded = collection.isLoaded()
+		if h.Loaded {
+			h.StaleSeconds = now.Sub(h.LastSync).Seconds()
+		}
+		res.Healthy = res.Healthy && h.Loaded && h.ConsecutiveFailures == 0
+		res.Collections = append(res.Collections, h)
+	}
+	sort.Slice(res.Collections, func(i, j int) bool {
+		return res.Collections[i].AppId < res.Collections[j].AppId
+	})
+	return res
+}
+
+// 健康检查，响应体为Health的json。
+// maxStaleness大于0时，任一配置集合超过该时长未与服务端同步即视为不健康；不健康时返回503
+func (c *Client) HealthHandler(maxStaleness time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := c.Health()
+		if maxStaleness > 0 {
+			for _, collection := range health.Collections {
+				if collection.StaleSeconds > maxStaleness.Seconds() {
+					health.Healthy = false
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !health.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(health)
+	})
+}
+
+// 默认客户端的健康状态，见Client.Health
+func GetHealth() Health {
+	return std.Health()
+}
+
+// 默认客户端的健康检查，见Client.HealthHandler
+func HealthHandler(maxStaleness time.Duration) http.Handler {
+	return std.HealthHandler(maxStaleness)
+}
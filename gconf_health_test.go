@@ -0,0 +1,77 @@
+package gconf
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	var watchDown int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/getConfigApp":
+			w.Write([]byte(`{"configCollectionId":"app","name":"app"}`))
+		case "/listConfigs":
+			w.Write([]byte(`{"a":"1","b":"2"}`))
+		case "/watch":
+			if atomic.LoadInt32(&watchDown) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			time.Sleep(time.Millisecond * 20)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient("app", WithEndpoints(server.URL))
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		h := c.Health()
+		return h.Healthy && !h.Collections[0].LastWatchSuccess.IsZero()
+	})
+	h := c.Health().Collections[0]
+	if h.AppId != "app" || h.Keys != 2 || h.Hash != hashConfigs(map[string]string{"a": "1", "b": "2"}) || h.LastRefresh.IsZero() {
+		t.Fatalf("unexpected health %+v", h)
+	}
+
+	atomic.StoreInt32(&watchDown, 1)
+	waitFor(t, func() bool {
+		return c.Health().Collections[0].ConsecutiveFailures > 0
+	})
+	if h := c.Health(); h.Healthy || h.Collections[0].LastError == "" {
+		t.Fatalf("unexpected health %+v", h)
+	}
+	rec := httptest.NewRecorder()
+	c.HealthHandler(0).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected code %d", rec.Code)
+	}
+}
+
+func TestHashConfigs(t *testing.T) {
+	if hashConfigs(map[string]string{"a": "1", "b": "2"}) != hashConfigs(map[string]string{"b": "2", "a": "1"}) {
+		t.Fatal("hash should not depend on map order")
+	}
+	if hashConfigs(map[string]string{"a": "12"}) == hashConfigs(map[string]string{"a1": "2"}) {
+		t.Fatal("hash should separate keys and values")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+}
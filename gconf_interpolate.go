@@ -0,0 +1,210 @@
+package gconf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	minLookupBackoff = time.Second
+	maxLookupBackoff = time.Minute
+)
+
+var (
+	ErrInterpolationCycle = errors.New("gconf: interpolation cycle")
+	ErrUnresolved         = errors.New("gconf: unresolved placeholder")
+)
+
+// 占位符引用的配置
+type ref struct {
+	appId, key string
+}
+
+func (r ref) String() string {
+	return r.appId + ":" + r.key
+}
+
+type placeholder struct {
+	env    string // 引用环境变量时不为空
+	ref    ref
+	def    string
+	hasDef bool
+}
+
+// 解析${}中的表达式：key、app:key、env:NAME，均可带:-default
+func parsePlaceholder(appId, expr string) placeholder {
+	name, def, hasDef := strings.Cut(expr, ":-")
+	p := placeholder{def: def, hasDef: hasDef}
+	if env, ok := strings.CutPrefix(name, "env:"); ok {
+		p.env = env
+	} else if app, key, ok := strings.Cut(name, ":"); ok {
+		p.ref = ref{appId: app, key: key}
+	} else {
+		p.ref = ref{appId: appId, key: name}
+	}
+	return p
+}
+
+// 将s中的占位符替换为resolve的结果，$${转义为${。
+// 解析失败的占位符原样保留，返回第一个错误
+func expand(s string, resolve func(expr string) (string, error)) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var b strings.Builder
+	var firstErr error
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			break
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			b.WriteString(s)
+			break
+		}
+		end += i + 2
+		b.WriteString(s[:i])
+		v, err := resolve(s[i+2 : end])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			v = s[i : end+1]
+		}
+		b.WriteString(v)
+		s = s[end+1:]
+	}
+	return b.String(), firstErr
+}
+
+// 值中直接引用的配置，不含环境变量
+func refsOf(appId, s string) []ref {
+	var refs []ref
+	expand(s, func(expr string) (string, error) {
+		if p := parsePlaceholder(appId, expr); p.env == "" {
+			refs = append(refs, p.ref)
+		}
+		return "", nil
+	})
+	return refs
+}
+
+// 解析配置r的值中的占位符，stack为正在解析的引用链，用于检测循环引用
+func (c *Client) interpolate(r ref, value string, stack []ref) (string, error) {
+	stack = append(stack, r)
+	return expand(value, func(expr string) (string, error) {
+		p := parsePlaceholder(r.appId, expr)
+		if p.env != "" {
+			v, ok := os.LookupEnv(p.env)
+			if v == "" && p.hasDef {
+				return p.def, nil
+			}
+			if !ok {
+				return "", fmt.Errorf("%w ${%s} in %s: environment variable not set", ErrUnresolved, expr, r)
+			}
+			return v, nil
+		}
+		for i, s := range stack {
+			if s == p.ref {
+				chain := make([]string, 0, len(stack)-i+1)
+				for _, x := range stack[i:] {
+					chain = append(chain, x.String())
+				}
+				return "", fmt.Errorf("%w: %s -> %s", ErrInterpolationCycle, strings.Join(chain, " -> "), p.ref)
+			}
+		}
+		v := c.lookup(p.ref)
+		if v == nil {
+			if p.hasDef {
+				return p.def, nil
+			}
+			return "", fmt.Errorf("%w ${%s} in %s: key not found", ErrUnresolved, expr, r)
+		}
+		return c.interpolate(p.ref, v.Template(), stack)
+	})
+}
+
+// 获取失败的引用集合，在retryAt之前不再请求服务端
+type lookupFailure struct {
+	retryAt time.Time
+	backoff time.Duration
+}
+
+// 查找引用的配置，引用其他集合时按需获取该集合。
+// 获取失败后按指数退避重试，避免每次解析都同步请求服务端
+func (c *Client) lookup(r ref) *Value {
+	c.mux.RLock()
+	collection, ok := c.dataCache[r.appId]
+	failure, failed := c.lookupFailures[r.appId]
+	c.mux.RUnlock()
+	if !ok {
+		if failed && time.Now().Before(failure.retryAt) {
+			return nil
+		}
+		collection = c.getConfigCollection(r.appId)
+		c.mux.Lock()
+		if collection != nil {
+			delete(c.lookupFailures, r.appId)
+		} else {
+			failure.backoff = min(max(failure.backoff*2, minLookupBackoff), maxLookupBackoff)
+			failure.retryAt = time.Now().Add(failure.backoff)
+			c.lookupFailures[r.appId] = failure
+		}
+		c.mux.Unlock()
+		if collection == nil {
+			return nil
+		}
+	}
+	v := collection.GetValue(r.key)
+	if v == nil || v.isRemoved() {
+		return nil
+	}
+	return v
+}
+
+// 返回直接或间接引用了appId中keys的配置及其当前解析后的值，不含keys本身。
+// 在配置变化前调用，变化后比较解析结果以通知引用方
+func (c *Client) dependents(appId string, keys []string) map[*Value]string {
+	reverse := map[ref][]*Value{}
+	for _, collection := range c.collections() {
+		for _, v := range collection.values() {
+			for _, r := range refsOf(collection.appId, v.Template()) {
+				reverse[r] = append(reverse[r], v)
+			}
+		}
+	}
+	if len(reverse) == 0 {
+		return nil
+	}
+
+	changed := make(map[string]bool, len(keys))
+	queue := make([]ref, 0, len(keys))
+	for _, k := range keys {
+		changed[k] = true
+		queue = append(queue, ref{appId: appId, key: k})
+	}
+	res := map[*Value]string{}
+	for len(queue) > 0 {
+		r := queue[0]
+		queue = queue[1:]
+		for _, v := range reverse[r] {
+			if _, ok := res[v]; ok || (v.collection.appId == appId && changed[v.key]) {
+				continue
+			}
+			res[v] = v.Raw()
+			queue = append(queue, ref{appId: v.collection.appId, key: v.key})
+		}
+	}
+	return res
+}
@@ -0,0 +1,161 @@
+package gconf_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+func TestInterpolation(t *testing.T) {
+	t.Setenv("GCONF_TEST_DB_NAME", "orders")
+	c := gconftest.NewClient(t, "app", gconf.WithInterpolation())
+	c.Provider.SetCollection("shared", map[string]string{"db.host": "10.0.0.1"})
+	c.Provider.SetCollection("app", map[string]string{
+		"port":          "3306",
+		"dsn":           "mysql://${shared:db.host}:${port}/${env:GCONF_TEST_DB_NAME}?tz=${env:GCONF_TEST_UNSET:-UTC}",
+		"db.properties": "dsn=${dsn}\nuser=${user:-root}",
+		"escaped":       "$${port}",
+		"missing":       "${nope}",
+		"a":             "${b}",
+		"b":             "${a}",
+	})
+	collection := c.GetCurrentConfigCollection()
+
+	want := "mysql://10.0.0.1:3306/orders?tz=UTC"
+	if got := collection.GetValue("dsn").Raw(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := collection.GetValue("dsn").Template(); got != "mysql://${shared:db.host}:${port}/${env:GCONF_TEST_DB_NAME}?tz=${env:GCONF_TEST_UNSET:-UTC}" {
+		t.Errorf("template changed: %q", got)
+	}
+	if p := collection.GetValue("db.properties").AsProperties(); p["dsn"] != want || p["user"] != "root" {
+		t.Errorf("unexpected properties %v", p)
+	}
+	if got := collection.GetValue("escaped").Raw(); got != "${port}" {
+		t.Errorf("escape not applied: %q", got)
+	}
+	if got, err := collection.GetValue("missing").Resolve(); !errors.Is(err, gconf.ErrUnresolved) || got != "${nope}" {
+		t.Errorf("expected unresolved error, got %q %v", got, err)
+	}
+	if got, err := collection.GetValue("a").Resolve(); !errors.Is(err, gconf.ErrInterpolationCycle) || got != "${b}" {
+		t.Errorf("expected cycle error, got %q %v", got, err)
+	}
+	if got := collection.AsMap()["dsn"]; got != want {
+		t.Errorf("AsMap not resolved: %q", got)
+	}
+}
+
+func TestInterpolationMissingApp(t *testing.T) {
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("app", map[string]string{"addr": "${nope:host:-localhost}:6379"})
+	c := gconf.NewClient("app", gconf.WithEndpoints(s.URL), gconf.WithInterpolation())
+	defer c.Close()
+	v := c.GetCurrentConfigCollection().GetValue("addr")
+
+	// 引用的集合不存在时，退避期内不再请求服务端
+	for i := 0; i < 5; i++ {
+		if got := v.Raw(); got != "localhost:6379" {
+			t.Fatalf("unexpected value %q", got)
+		}
+	}
+	n := 0
+	for _, r := range s.Requests("/getConfigApp") {
+		if r.Params.Get("configAppId") == "nope" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("expected 1 request for the missing app, got %d", n)
+	}
+}
+
+func TestInterpolationDisabled(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "a", "${b}")
+	c.SetValue(t, "app", "b", "x")
+	if got := c.GetCurrentConfigCollection().GetValue("a").Raw(); got != "${b}" {
+		t.Fatalf("placeholders resolved without WithInterpolation: %q", got)
+	}
+}
+
+type changeRecorder struct {
+	mux     sync.Mutex
+	changes []string
+}
+
+func (r *changeRecorder) listener() gconf.ConfigChangeListenerFunc {
+	return func(key, oldValue, newValue string) {
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.changes = append(r.changes, key+": "+oldValue+" -> "+newValue)
+	}
+}
+
+func (r *changeRecorder) take() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	res := r.changes
+	r.changes = nil
+	return res
+}
+
+func TestInterpolationDependents(t *testing.T) {
+	c := gconftest.NewClient(t, "app", gconf.WithInterpolation())
+	c.Provider.SetCollection("shared", map[string]string{"host": "h1"})
+	c.Provider.SetCollection("app", map[string]string{
+		"addr":       "${shared:host}:6379",
+		"redis.json": `{"addr":"${addr}","db":1}`,
+		"unrelated":  "x",
+		"cycle.a":    "${cycle.b}",
+		"cycle.b":    "${cycle.a}",
+	})
+	collection := c.GetCurrentConfigCollection()
+	rec := &changeRecorder{}
+	collection.AddCollectionChangeListener(rec.listener())
+
+	var cfg struct {
+		Addr string `json:"addr"`
+	}
+	if err := collection.GetValue("redis.json").Register(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != "h1:6379" {
+		t.Fatalf("unexpected registered value %q", cfg.Addr)
+	}
+
+	c.SetValue(t, "shared", "host", "h2")
+	changes := rec.take()
+	if len(changes) != 2 {
+		t.Fatalf("expected dependents to fire, got %v", changes)
+	}
+	for _, want := range []string{"addr: h1:6379 -> h2:6379", `redis.json: {"addr":"h1:6379","db":1} -> {"addr":"h2:6379","db":1}`} {
+		if changes[0] != want && changes[1] != want {
+			t.Errorf("missing change %q in %v", want, changes)
+		}
+	}
+	if cfg.Addr != "h2:6379" {
+		t.Errorf("registered bean not refreshed: %q", cfg.Addr)
+	}
+
+	// 引用方的模板变化时，按解析后的值通知
+	c.SetValue(t, "app", "addr", "${shared:host}:6380")
+	if changes := rec.take(); len(changes) != 2 || cfg.Addr != "h2:6380" {
+		t.Errorf("unexpected changes %v, bean %q", changes, cfg.Addr)
+	}
+
+	// 解析结果不变时不通知
+	c.SetValue(t, "shared", "unused", "1")
+	if changes := rec.take(); len(changes) != 0 {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	// 循环引用的配置变化时不会无限传播
+	c.SetValue(t, "app", "cycle.a", "${cycle.b}!")
+	if changes := rec.take(); len(changes) == 0 {
+		t.Error("expected change of cycle.a")
+	}
+}
@@ -0,0 +1,245 @@
+// Package gconf_log 根据配置动态调整日志级别，运维修改gconf即可临时打开某个服务的debug日志。
+//
+//	var level = new(slog.LevelVar)
+//	ctl := gconf_log.New(gconf.GetCurrentConfigCollection())
+//	defer ctl.Close()
+//	ctl.Bind("root", gconf_log.Slog(level))
+//	ctl.Bind("order.repo", gconf_zap.Level(atomicLevel))
+//
+// zap及logrus的适配分别在独立的module gconf_log/gconf_zap、gconf_log/gconf_logrus中，避免引入不使用的依赖。
+//
+// log-level.properties中每行为logger名及级别，未配置的logger依次使用上级名称(按.分隔)及root的级别，
+// 都没有时恢复为Bind时的级别。_ttl(如30m，从客户端收到配置时开始计时)或_expires(RFC3339时间)
+// 到期后所有logger恢复为Bind时的级别：
+//
+//	root=info
+//	order.repo=debug
+//	_ttl=30m
+package gconf_log
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+// 默认的日志级别配置key
+const DefaultKey = "log-level.properties"
+
+const (
+	rootName   = "root"
+	ttlKey     = "_ttl" // 以_开头，避免与logger名冲突
+	expiresKey = "_expires"
+)
+
+// 可动态调整级别的日志
+type Leveler interface {
+	Level() string
+	SetLevel(level string) error
+}
+
+type slogLevel struct {
+	v *slog.LevelVar
+}
+
+// 绑定slog.LevelVar，级别名称不区分大小写，支持INFO+2等slog写法
+func Slog(v *slog.LevelVar) Leveler {
+	return slogLevel{v: v}
+}
+
+func (l slogLevel) Level() string {
+	return l.v.Level().String()
+}
+
+func (l slogLevel) SetLevel(level string) error {
+	return l.v.UnmarshalText([]byte(level))
+}
+
+type binding struct {
+	leveler  Leveler
+	original string // Bind时的级别，未配置或过期时恢复
+}
+
+type options struct {
+	key    string
+	logger *slog.Logger
+}
+
+type Option func(*options)
+
+// 指定日志级别配置的key，默认为log-level.properties
+func WithKey(key string) Option {
+	return func(o *options) {
+		o.key = key
+	}
+}
+
+// 指定本包自身的日志输出，默认使用slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 将配置中的级别应用到绑定的日志
+type Controller struct {
+	logger *slog.Logger
+
+	mux      sync.Mutex
+	bindings map[string]*binding
+	levels   map[string]string // 当前配置，过期后为空
+	timer    *time.Timer
+	closed   bool
+
+	removeListener func()
+}
+
+// 读取集合中的日志级别配置并监听变化
+func New(c *gconf.ConfigCollection, opts ...Option) *Controller {
+	o := &options{key: DefaultKey}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	ctl := &Controller{
+		logger:   o.logger.With("component", "gconf_log", "appId", c.AppId(), "key", o.key),
+		bindings: map[string]*binding{},
+	}
+	if v := c.GetValue(o.key); v != nil {
+		ctl.update(v.Raw())
+	}
+	ctl.removeListener = c.AddConfigChangeListener(o.key, gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		ctl.update(newValue)
+	}))
+	return ctl
+}
+
+// 绑定名为name的日志，立即应用当前配置。重复绑定同名日志时替换原绑定
+func (ctl *Controller) Bind(name string, l Leveler) {
+	ctl.mux.Lock()
+	defer ctl.mux.Unlock()
+	b := &binding{leveler: l, original: l.Level()}
+	ctl.bindings[name] = b
+	ctl.applyLocked(name, b)
+}
+
+// 返回各绑定日志的当前级别
+func (ctl *Controller) Levels() map[string]string {
+	ctl.mux.Lock()
+	defer ctl.mux.Unlock()
+	res := make(map[string]string, len(ctl.bindings))
+	for name, b := range ctl.bindings {
+		res[name] = b.leveler.Level()
+	}
+	return res
+}
+
+// 停止过期计时，不再响应配置变化。不会恢复日志级别
+func (ctl *Controller) Close() {
+	ctl.removeListener()
+	ctl.mux.Lock()
+	defer ctl.mux.Unlock()
+	ctl.closed = true
+	ctl.stopTimerLocked()
+}
+
+func (ctl *Controller) update(value string) {
+	levels := map[string]string{}
+	for k, v := range gconf.ParseProperties(value) {
+		levels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	var expiresAt time.Time
+	if ttl, ok := levels[ttlKey]; ok {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			ctl.logger.Warn("invalid ttl", "ttl", ttl, "error", err)
+		} else {
+			expiresAt = time.Now().Add(d)
+		}
+	}
+	if expires, ok := levels[expiresKey]; ok {
+		t, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			ctl.logger.Warn("invalid expires", "expires", expires, "error", err)
+		} else if expiresAt.IsZero() || t.Before(expiresAt) {
+			expiresAt = t
+		}
+	}
+	delete(levels, ttlKey)
+	delete(levels, expiresKey)
+
+	ctl.mux.Lock()
+	defer ctl.mux.Unlock()
+	if ctl.closed {
+		return
+	}
+	ctl.stopTimerLocked()
+	if !expiresAt.IsZero() {
+		remaining := expiresAt.Sub(time.Now())
+		if remaining <= 0 {
+			levels = nil
+		} else {
+			ctl.timer = time.AfterFunc(remaining, ctl.expire)
+		}
+	}
+	ctl.levels = levels
+	for name, b := range ctl.bindings {
+		ctl.applyLocked(name, b)
+	}
+}
+
+// ttl到期，恢复所有日志的级别
+func (ctl *Controller) expire() {
+	ctl.mux.Lock()
+	defer ctl.mux.Unlock()
+	if ctl.closed {
+		return
+	}
+	ctl.logger.Info("log levels expired, restoring")
+	ctl.levels = nil
+	for name, b := range ctl.bindings {
+		ctl.applyLocked(name, b)
+	}
+}
+
+func (ctl *Controller) stopTimerLocked() {
+	if ctl.timer != nil {
+		ctl.timer.Stop()
+		ctl.timer = nil
+	}
+}
+
+func (ctl *Controller) applyLocked(name string, b *binding) {
+	level := ctl.levelLocked(name)
+	if level == "" {
+		level = b.original
+	}
+	if strings.EqualFold(level, b.leveler.Level()) {
+		return
+	}
+	if err := b.leveler.SetLevel(level); err != nil {
+		ctl.logger.Warn("invalid log level", "logger", name, "level", level, "error", err)
+		return
+	}
+	ctl.logger.Info("log level changed", "logger", name, "level", level)
+}
+
+// 按name、上级名称、root的顺序查找配置的级别
+func (ctl *Controller) levelLocked(name string) string {
+	for n := name; n != ""; {
+		if level, ok := ctl.levels[n]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(n, '.')
+		if i < 0 {
+			break
+		}
+		n = n[:i]
+	}
+	return ctl.levels[rootName]
+}
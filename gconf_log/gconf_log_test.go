@@ -0,0 +1,68 @@
+package gconf_log
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+var quiet = WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+func TestBind(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", DefaultKey, "root=warn\norder=debug\n# comment\npay.api=error")
+	ctl := New(c.GetCurrentConfigCollection(), quiet)
+	defer ctl.Close()
+
+	root, orderRepo, payApi := new(slog.LevelVar), new(slog.LevelVar), new(slog.LevelVar)
+	ctl.Bind("root", Slog(root))
+	ctl.Bind("order.repo", Slog(orderRepo))
+	ctl.Bind("pay.api", Slog(payApi))
+
+	if root.Level() != slog.LevelWarn || orderRepo.Level() != slog.LevelDebug || payApi.Level() != slog.LevelError {
+		t.Fatalf("levels not applied: %v", ctl.Levels())
+	}
+
+	c.SetValue(t, "app", DefaultKey, "order.repo=info\npay.api=nope")
+	if root.Level() != slog.LevelInfo || orderRepo.Level() != slog.LevelInfo || payApi.Level() != slog.LevelError {
+		t.Fatalf("unexpected levels after change: %v", ctl.Levels())
+	}
+
+	c.SetValue(t, "app", "other", "x") //删除集合中最后一个key时不会通知
+	c.DeleteValue(t, "app", DefaultKey)
+	if payApi.Level() != slog.LevelInfo {
+		t.Fatalf("original level not restored: %v", ctl.Levels())
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	ctl := New(c.GetCurrentConfigCollection(), quiet)
+	defer ctl.Close()
+	level := new(slog.LevelVar)
+	ctl.Bind("root", Slog(level))
+
+	c.SetValue(t, "app", DefaultKey, "root=debug\n_ttl=50ms")
+	if level.Level() != slog.LevelDebug {
+		t.Fatal("level not applied")
+	}
+	deadline := time.Now().Add(time.Second * 5)
+	for level.Level() != slog.LevelInfo {
+		if time.Now().After(deadline) {
+			t.Fatal("level not reverted after ttl")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	c.SetValue(t, "app", DefaultKey, "root=debug\n_expires="+time.Now().Add(-time.Minute).Format(time.RFC3339))
+	if level.Level() != slog.LevelInfo {
+		t.Fatal("expired config should not be applied")
+	}
+	c.SetValue(t, "app", DefaultKey, "root=debug\n_expires="+time.Now().Add(time.Hour).Format(time.RFC3339))
+	if level.Level() != slog.LevelDebug {
+		t.Fatal("level not applied before expiry")
+	}
+}
@@ -0,0 +1,31 @@
+// Package gconf_logrus 将logrus的日志级别绑定到gconf_log。
+//
+//	ctl.Bind("pay.api", gconf_logrus.Level(logger))
+package gconf_logrus
+
+import (
+	"github.com/guanaitong/gconf-go-client/gconf_log"
+	"github.com/sirupsen/logrus"
+)
+
+type level struct {
+	l *logrus.Logger
+}
+
+// 绑定logrus.Logger的级别
+func Level(l *logrus.Logger) gconf_log.Leveler {
+	return level{l: l}
+}
+
+func (l level) Level() string {
+	return l.l.GetLevel().String()
+}
+
+func (l level) SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.l.SetLevel(lvl)
+	return nil
+}
@@ -0,0 +1,33 @@
+package gconf_logrus
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client/gconf_log"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLevel(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", gconf_log.DefaultKey, "pay.api=error")
+	ctl := gconf_log.New(c.GetCurrentConfigCollection(), gconf_log.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer ctl.Close()
+
+	l := logrus.New()
+	ctl.Bind("pay.api", Level(l))
+	if l.GetLevel() != logrus.ErrorLevel {
+		t.Fatalf("level not applied: %v", ctl.Levels())
+	}
+	c.SetValue(t, "app", gconf_log.DefaultKey, "pay.api=nope")
+	if l.GetLevel() != logrus.ErrorLevel {
+		t.Fatalf("invalid level should be ignored: %v", ctl.Levels())
+	}
+	c.SetValue(t, "app", "other", "x") //删除集合中最后一个key时不会通知
+	c.DeleteValue(t, "app", gconf_log.DefaultKey)
+	if l.GetLevel() != logrus.InfoLevel {
+		t.Fatalf("original level not restored: %v", ctl.Levels())
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/guanaitong/gconf-go-client/gconf_log/gconf_logrus
+
+go 1.23
+
+require (
+	github.com/guanaitong/gconf-go-client v0.0.0-00010101000000-000000000000
+	github.com/sirupsen/logrus v1.10.2
+)
+
+require (
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+)
+
+replace github.com/guanaitong/gconf-go-client => ../..
@@ -0,0 +1,29 @@
+// Package gconf_zap 将zap的日志级别绑定到gconf_log。
+//
+//	atomicLevel := zap.NewAtomicLevel()
+//	ctl.Bind("order.repo", gconf_zap.Level(atomicLevel))
+package gconf_zap
+
+import (
+	"strings"
+
+	"github.com/guanaitong/gconf-go-client/gconf_log"
+	"go.uber.org/zap"
+)
+
+type level struct {
+	l zap.AtomicLevel
+}
+
+// 绑定zap.AtomicLevel，级别名称不区分大小写
+func Level(l zap.AtomicLevel) gconf_log.Leveler {
+	return level{l: l}
+}
+
+func (l level) Level() string {
+	return l.l.Level().String()
+}
+
+func (l level) SetLevel(level string) error {
+	return l.l.UnmarshalText([]byte(strings.ToLower(level)))
+}
@@ -0,0 +1,32 @@
+package gconf_zap
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client/gconf_log"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+	"go.uber.org/zap"
+)
+
+func TestLevel(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", gconf_log.DefaultKey, "order=DEBUG")
+	ctl := gconf_log.New(c.GetCurrentConfigCollection(), gconf_log.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer ctl.Close()
+
+	l := zap.NewAtomicLevelAt(zap.InfoLevel)
+	ctl.Bind("order.repo", Level(l))
+	if l.Level() != zap.DebugLevel {
+		t.Fatalf("level not applied: %v", ctl.Levels())
+	}
+	c.SetValue(t, "app", gconf_log.DefaultKey, "order=nope")
+	if l.Level() != zap.DebugLevel {
+		t.Fatalf("invalid level should be ignored: %v", ctl.Levels())
+	}
+	c.SetValue(t, "app", gconf_log.DefaultKey, "root=warn")
+	if l.Level() != zap.WarnLevel {
+		t.Fatalf("root level not applied: %v", ctl.Levels())
+	}
+}
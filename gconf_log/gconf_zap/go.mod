@@ -0,0 +1,16 @@
+module github.com/guanaitong/gconf-go-client/gconf_log/gconf_zap
+
+go 1.21
+
+require (
+	github.com/guanaitong/gconf-go-client v0.0.0-00010101000000-000000000000
+	go.uber.org/zap v1.28.0
+)
+
+require (
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+)
+
+replace github.com/guanaitong/gconf-go-client => ../..
@@ -0,0 +1,165 @@
+package gconf
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// 配置来源，默认为gconf服务端
+type source interface {
+	getConfigApp(ctx context.Context, appId string) (*ConfigApp, error)
+	listConfigs(ctx context.Context, appId string) (map[string]string, error)
+	watch(ctx context.Context, configAppIds []string) ([]string, error)
+}
+
+var _ source = (*gConfHttpClient)(nil)
+var _ source = (*memorySource)(nil)
+
+type memoryCollection struct {
+	data    map[string]string
+	version int64
+}
+
+// 内存配置源，通过WithMemoryProvider使用。修改后由客户端的后台watch感知，
+// 也可调用Client.Refresh立即生效。可被多个客户端共用
+type MemoryProvider struct {
+	mux         sync.Mutex
+	changed     chan struct{} // 配置变化时关闭并重建，用于唤醒watch
+	collections map[string]*memoryCollection
+}
+
+func NewMemoryProvider() *MemoryProvider {
+	return &MemoryProvider{
+		changed:     make(chan struct{}),
+		collections: map[string]*memoryCollection{},
+	}
+}
+
+// 创建或替换配置集合
+func (p *MemoryProvider) SetCollection(appId string, data map[string]string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	c := p.collectionLocked(appId)
+	c.data = make(map[string]string, len(data))
+	for k, v := range data {
+		c.data[k] = v
+	}
+	p.changedLocked(c)
+}
+
+// 设置单个配置项，集合不存在时自动创建
+func (p *MemoryProvider) Set(appId, key, value string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	c := p.collectionLocked(appId)
+	c.data[key] = value
+	p.changedLocked(c)
+}
+
+// 删除单个配置项
+func (p *MemoryProvider) Delete(appId, key string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if c, ok := p.collections[appId]; ok {
+		delete(c.data, key)
+		p.changedLocked(c)
+	}
+}
+
+// 返回配置项的当前值
+func (p *MemoryProvider) Get(appId, key string) (string, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if c, ok := p.collections[appId]; ok {
+		v, ok := c.data[key]
+		return v, ok
+	}
+	return "", false
+}
+
+// 返回配置集合的副本，集合不存在时返回false
+func (p *MemoryProvider) Collection(appId string) (map[string]string, bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	c, ok := p.collections[appId]
+	if !ok {
+		return nil, false
+	}
+	res := make(map[string]string, len(c.data))
+	for k, v := range c.data {
+		res[k] = v
+	}
+	return res, true
+}
+
+func (p *MemoryProvider) collectionLocked(appId string) *memoryCollection {
+	c, ok := p.collections[appId]
+	if !ok {
+		c = &memoryCollection{data: map[string]string{}}
+		p.collections[appId] = c
+	}
+	return c
+}
+
+func (p *MemoryProvider) changedLocked(c *memoryCollection) {
+	c.version++
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// 每个客户端一个，记录已通知的版本
+type memorySource struct {
+	provider *MemoryProvider
+	seen     map[string]int64
+}
+
+func newMemorySource(p *MemoryProvider) *memorySource {
+	return &memorySource{provider: p, seen: map[string]int64{}}
+}
+
+func (m *memorySource) getConfigApp(ctx context.Context, appId string) (*ConfigApp, error) {
+	m.provider.mux.Lock()
+	defer m.provider.mux.Unlock()
+	if _, ok := m.provider.collections[appId]; !ok {
+		return nil, fmt.Errorf("config app %s not found", appId)
+	}
+	return &ConfigApp{AppId: appId, Name: appId}, nil
+}
+
+func (m *memorySource) listConfigs(ctx context.Context, appId string) (map[string]string, error) {
+	if data, ok := m.provider.Collection(appId); ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("config app %s not found", appId)
+}
+
+// 返回上次watch后版本变化的appId，没有变化时等待直到变化或ctx结束
+func (m *memorySource) watch(ctx context.Context, configAppIds []string) ([]string, error) {
+	for {
+		p := m.provider
+		p.mux.Lock()
+		var changedAppIds []string
+		for _, appId := range configAppIds {
+			c, ok := p.collections[appId]
+			if !ok {
+				continue
+			}
+			if v, ok := m.seen[appId]; !ok || v != c.version {
+				changedAppIds = append(changedAppIds, appId)
+			}
+			m.seen[appId] = c.version
+		}
+		changed := p.changed
+		p.mux.Unlock()
+
+		if len(changedAppIds) > 0 {
+			return changedAppIds, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
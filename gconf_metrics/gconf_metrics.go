@@ -0,0 +1,176 @@
+package gconf_metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconf_flags"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gconf客户端的Prometheus指标，实现gconf.Observer、gconf_flags.Observer及prometheus.Collector。
+//
+//	m := gconf_metrics.New()
+//	gconf.Init("app", gconf.WithObserver(m))
+//	m.AddClient(gconf.Default())
+//	flags, err := gconf_flags.New(gconf.GetCurrentConfigCollection(), gconf_flags.WithObserver(m))
+//	prometheus.MustRegister(m)
+type Metrics struct {
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	watches          *prometheus.CounterVec
+	refreshes        *prometheus.CounterVec
+	keysChanged      *prometheus.CounterVec
+	listenerDuration *prometheus.HistogramVec
+	listenerPanics   *prometheus.CounterVec
+	flagEvaluations  *prometheus.CounterVec
+	staleness        *prometheus.Desc
+	failures         *prometheus.Desc
+
+	mux     sync.Mutex
+	clients []*gconf.Client
+}
+
+var _ gconf.Observer = (*Metrics)(nil)
+var _ gconf_flags.Observer = (*Metrics)(nil)
+var _ prometheus.Collector = (*Metrics)(nil)
+
+func New() *Metrics {
+	return &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gconf",
+			Name:      "requests_total",
+			Help:      "gconf server requests by endpoint, path and status code.",
+		}, []string{"endpoint", "path", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gconf",
+			Name:      "request_duration_seconds",
+			Help:      "gconf server request latencies by endpoint and path.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"endpoint", "path"}),
+		watches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gconf",
+			Name:      "watch_iterations_total",
+			Help:      "Background watch loop iterations by result.",
+		}, []string{"result"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gconf",
+			Name:      "refreshes_total",
+			Help:      "Config collection refreshes by app id and result.",
+		}, []string{"app_id", "result"}),
+		keysChanged: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gconf",
+			Name:      "keys_changed_total",
+			Help:      "Keys added, changed or removed by refreshes, by app id.",
+		}, []string{"app_id"}),
+		listenerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gconf",
+			Name:      "listener_duration_seconds",
+			Help:      "Config change listener durations by app id and key.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"app_id", "key"}),
+		listenerPanics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gconf",
+			Name:      "listener_panics_total",
+			Help:      "Config change listener panics by app id and key.",
+		}, []string{"app_id", "key"}),
+		flagEvaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gconf",
+			Name:      "flag_evaluations_total",
+			Help:      "Number of feature flag evaluations by flag, variant and reason.",
+		}, []string{"flag", "variant", "reason"}),
+		staleness: prometheus.NewDesc("gconf_collection_staleness_seconds",
+			"Seconds since the collection was last confirmed in sync with the server.",
+			[]string{"app_id"}, nil),
+		failures: prometheus.NewDesc("gconf_collection_consecutive_failures",
+			"Consecutive refresh or watch failures of the collection.",
+			[]string{"app_id"}, nil),
+	}
+}
+
+// 采集该客户端各配置集合的同步延迟
+func (m *Metrics) AddClient(c *gconf.Client) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.clients = append(m.clients, c)
+}
+
+func (m *Metrics) ObserveRequest(endpoint, path string, statusCode int, d time.Duration, err error) {
+	code := "error"
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	m.requests.WithLabelValues(endpoint, path, code).Inc()
+	m.requestDuration.WithLabelValues(endpoint, path).Observe(d.Seconds())
+}
+
+func (m *Metrics) ObserveWatch(appIds []string, changed int, err error) {
+	if err != nil {
+		m.watches.WithLabelValues("error").Inc()
+	} else if changed > 0 {
+		m.watches.WithLabelValues("changed").Inc()
+	} else {
+		m.watches.WithLabelValues("unchanged").Inc()
+	}
+}
+
+func (m *Metrics) ObserveRefresh(appId string, keysChanged int, err error) {
+	if err != nil {
+		m.refreshes.WithLabelValues(appId, "error").Inc()
+		return
+	}
+	m.refreshes.WithLabelValues(appId, "success").Inc()
+	m.keysChanged.WithLabelValues(appId).Add(float64(keysChanged))
+}
+
+func (m *Metrics) ObserveListener(appId, key string, d time.Duration, panicked bool) {
+	m.listenerDuration.WithLabelValues(appId, key).Observe(d.Seconds())
+	if panicked {
+		m.listenerPanics.WithLabelValues(appId, key).Inc()
+	}
+}
+
+func (m *Metrics) ObserveEvaluation(e gconf_flags.Evaluation) {
+	m.flagEvaluations.WithLabelValues(e.Flag, e.Variant, e.Reason).Inc()
+}
+
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requests.Describe(ch)
+	m.requestDuration.Describe(ch)
+	m.watches.Describe(ch)
+	m.refreshes.Describe(ch)
+	m.keysChanged.Describe(ch)
+	m.listenerDuration.Describe(ch)
+	m.listenerPanics.Describe(ch)
+	m.flagEvaluations.Describe(ch)
+	ch <- m.staleness
+	ch <- m.failures
+}
+
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.requests.Collect(ch)
+	m.requestDuration.Collect(ch)
+	m.watches.Collect(ch)
+	m.refreshes.Collect(ch)
+	m.keysChanged.Collect(ch)
+	m.listenerDuration.Collect(ch)
+	m.listenerPanics.Collect(ch)
+	m.flagEvaluations.Collect(ch)
+
+	m.mux.Lock()
+	clients := append([]*gconf.Client(nil), m.clients...)
+	m.mux.Unlock()
+	seen := map[string]bool{}
+	for _, c := range clients {
+		for _, h := range c.Health().Collections {
+			if seen[h.AppId] || !h.Loaded { //多个客户端加载同一集合时只上报一次
+				continue
+			}
+			seen[h.AppId] = true
+			ch <- prometheus.MustNewConstMetric(m.staleness, prometheus.GaugeValue, h.StaleSeconds, h.AppId)
+			ch <- prometheus.MustNewConstMetric(m.failures, prometheus.GaugeValue, float64(h.ConsecutiveFailures), h.AppId)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package gconf_metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconf_flags"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/getConfigApp":
+			w.Write([]byte(`{"configCollectionId":"app","name":"app"}`))
+		case "/listConfigs":
+			w.Write([]byte(`{"a":"1","b":"2"}`))
+		case "/watch":
+			time.Sleep(time.Millisecond * 20)
+		}
+	}))
+	defer server.Close()
+
+	m := New()
+	c := gconf.NewClient("app", gconf.WithEndpoints(server.URL), gconf.WithObserver(m))
+	defer c.Close()
+	m.AddClient(c)
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := testutil.ToFloat64(m.requests.WithLabelValues(server.URL, "/listConfigs", "200")); v != 1 {
+		t.Fatalf("unexpected listConfigs requests %v", v)
+	}
+	if v := testutil.ToFloat64(m.keysChanged.WithLabelValues("app")); v != 2 {
+		t.Fatalf("unexpected keys changed %v", v)
+	}
+
+	m.ObserveListener("app", "a", time.Millisecond, true)
+	if v := testutil.ToFloat64(m.listenerPanics.WithLabelValues("app", "a")); v != 1 {
+		t.Fatalf("unexpected listener panics %v", v)
+	}
+	m.ObserveWatch([]string{"app"}, 0, errors.New("down"))
+	if v := testutil.ToFloat64(m.watches.WithLabelValues("error")); v != 1 {
+		t.Fatalf("unexpected watch errors %v", v)
+	}
+
+	m.ObserveEvaluation(gconf_flags.Evaluation{Flag: "checkout", Variant: gconf_flags.On, Reason: gconf_flags.ReasonAllow})
+	if v := testutil.ToFloat64(m.flagEvaluations.WithLabelValues("checkout", gconf_flags.On, gconf_flags.ReasonAllow)); v != 1 {
+		t.Fatalf("unexpected flag evaluations %v", v)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+	if n, err := testutil.GatherAndCount(reg, "gconf_collection_staleness_seconds"); err != nil || n != 1 {
+		t.Fatalf("unexpected staleness series %d, %v", n, err)
+	}
+}
@@ -3,6 +3,8 @@ package gconf_mongo
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/guanaitong/gconf-go-client"
@@ -41,9 +43,30 @@ type MongoConfig struct {
 	MinPoolSize     uint64        `json:"minPoolSize"`
 	MaxConnIdleTime time.Duration `json:"maxConnIdleTime"`
 	SocketTimeout   time.Duration `json:"socketTimeout"`
+
+	collection *gconf.ConfigCollection // 配置所在的集合，用于错误信息
+	key        string
+}
+
+// 字段field相关的错误
+func (mongoConfig *MongoConfig) fieldError(field string, err error) error {
+	e := &gconf.ConfigError{Key: mongoConfig.key, Field: field, Err: err}
+	if mongoConfig.collection != nil {
+		e.AppId = mongoConfig.collection.AppId()
+	}
+	return e
 }
 
 func (mongoConfig *MongoConfig) NewClient() *MongoClient {
+	mc, err := mongoConfig.NewClientE()
+	if err != nil {
+		panic(err)
+	}
+	return mc
+}
+
+// 同NewClient，uri无效、类型不支持或连接失败时返回错误
+func (mongoConfig *MongoConfig) NewClientE() (*MongoClient, error) {
 	ctx := context.Background()
 	mc := &MongoClient{Context: ctx}
 
@@ -62,7 +85,7 @@ func (mongoConfig *MongoConfig) NewClient() *MongoClient {
 
 	conStr, err := connstring.Parse(mongoConfig.URI)
 	if err != nil {
-		panic(err)
+		return nil, mongoConfig.fieldError("uri", err)
 	}
 	if mongoConfig.DBName == "" {
 		mongoConfig.DBName = conStr.Database
@@ -78,7 +101,7 @@ func (mongoConfig *MongoConfig) NewClient() *MongoClient {
 	} else if mongoConfig.Type == MongoShardCluster {
 
 	} else {
-		panic("unsupported type")
+		return nil, mongoConfig.fieldError("type", fmt.Errorf("unsupported type %d", mongoConfig.Type))
 	}
 
 	clientOption := options.Client().
@@ -90,27 +113,47 @@ func (mongoConfig *MongoConfig) NewClient() *MongoClient {
 
 	client, err := mongo.Connect(ctx, clientOption)
 	if err != nil {
-		panic(err)
+		return nil, mongoConfig.fieldError("", err)
 	}
 	mc.Client = client
 	mc.DBCon = client.Database(conStr.Database)
 
-	return mc
+	return mc, nil
 }
 
 func GetDefaultMongoConfig() *MongoConfig {
 	return GetMongoConfig(defaultMongoConfigKey)
 }
 
+// 同GetDefaultMongoConfig，读取失败时返回错误
+func GetDefaultMongoConfigE() (*MongoConfig, error) {
+	return GetMongoConfigE(defaultMongoConfigKey)
+}
+
 func GetMongoConfig(key string) *MongoConfig {
+	mongoConfig, err := GetMongoConfigE(key)
+	if err != nil {
+		panic(err)
+	}
+	return mongoConfig
+}
+
+// 同GetMongoConfig，key为空、不存在或无法解析时返回错误
+func GetMongoConfigE(key string) (*MongoConfig, error) {
 	if key == "" {
-		panic("mongo datasource key is empty")
+		return nil, errors.New("mongo datasource key is empty")
+	}
+	configValue, err := gconf.Lookup(key)
+	if err != nil {
+		return nil, err
 	}
+	return parseMongoConfig(gconf.GetCurrentConfigCollection(), key, configValue)
+}
 
-	mongoConfig := new(MongoConfig)
-	configValue := gconf.GetCurrentConfigCollection().GetValue(key).Raw()
-	if err := json.Unmarshal([]byte(configValue), mongoConfig); err != nil {
-		panic(err)
+func parseMongoConfig(c *gconf.ConfigCollection, key, value string) (*MongoConfig, error) {
+	mongoConfig := &MongoConfig{collection: c, key: key}
+	if err := json.Unmarshal([]byte(value), mongoConfig); err != nil {
+		return nil, &gconf.ConfigError{AppId: c.AppId(), Key: key, Err: err}
 	}
-	return mongoConfig
+	return mongoConfig, nil
 }
@@ -1,13 +1,26 @@
 package gconf_mongo
 
 import (
+	"errors"
+	"os"
 	"testing"
 
 	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
 )
 
+// 设置GCONF_TEST_MONGO_CONFIG为mongo-config.json的内容时，使用该配置测试
+const testMongoConfig = `{"type":1,"uri":"mongodb://127.0.0.1:27017/saas?replicaSet=rs0","maxPoolSize":5}`
+
 func TestMongoConfig_NewClient(t *testing.T) {
-	gconf.Init("gce-api-saas")
+	s := gconftest.NewServer()
+	defer s.Close()
+	mongoConfig, integration := os.LookupEnv("GCONF_TEST_MONGO_CONFIG")
+	if !integration {
+		mongoConfig = testMongoConfig
+	}
+	s.SetCollection("gce-api-saas", map[string]string{"mongo-config.json": mongoConfig})
+	gconf.Init("gce-api-saas", gconf.WithEndpoints(s.URL))
 	c := GetDefaultMongoConfig()
 	if c == nil {
 		t.Error("c is nil")
@@ -17,4 +30,38 @@ func TestMongoConfig_NewClient(t *testing.T) {
 	if client == nil {
 		t.Error("client is nil")
 	}
+	if !integration && (c.DBName != "saas" || c.ReplicaSet.ReplicaName != "rs0" || c.MaxPoolSize != 5) {
+		t.Errorf("unexpected config %+v", c)
+	}
+}
+
+func TestGetMongoConfigE(t *testing.T) {
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("mongo-e", map[string]string{
+		"bad.json":  `{"type":"x"}`,
+		"uri.json":  `{"uri":"http://127.0.0.1"}`,
+		"type.json": `{"type":7,"uri":"mongodb://127.0.0.1:27017/saas"}`,
+	})
+	gconf.Init("mongo-e", gconf.WithEndpoints(s.URL))
+
+	if _, err := GetMongoConfigE(""); err == nil {
+		t.Error("empty key should fail")
+	}
+	if _, err := GetDefaultMongoConfigE(); !errors.Is(err, gconf.ErrNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+	var ce *gconf.ConfigError
+	if _, err := GetMongoConfigE("bad.json"); !errors.As(err, &ce) || ce.AppId != "mongo-e" || ce.Key != "bad.json" {
+		t.Errorf("unexpected error %v", err)
+	}
+	for key, field := range map[string]string{"uri.json": "uri", "type.json": "type"} {
+		config, err := GetMongoConfigE(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = config.NewClientE(); !errors.As(err, &ce) || ce.AppId != "mongo-e" || ce.Key != key || ce.Field != field {
+			t.Errorf("%s: unexpected error %v", key, err)
+		}
+	}
 }
@@ -0,0 +1,164 @@
+package gconf_mysql
+
+import (
+	"database/sql"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/internal/swap"
+)
+
+// 数据源中服务器的角色
+type Role string
+
+const (
+	RoleMaster Role = "master"
+	// 固定连接配置中的最后一个从库，没有从库时使用主库。
+	// 不会在多个从库间负载均衡，需要时使用Router
+	RoleSlave Role = "slave"
+)
+
+const defaultDrainDelay = 30 * time.Second
+
+type options struct {
+	role       Role
+	logger     *slog.Logger
+	drainDelay time.Duration
+}
+
+type Option func(*options)
+
+// 指定连接的角色，默认为RoleMaster
+func WithRole(role Role) Option {
+	return func(o *options) {
+		o.role = role
+	}
+}
+
+// 指定日志输出，默认使用slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 切换连接池后等待多久关闭旧连接池，默认30s。
+// 期间已取得旧连接池的调用方仍可使用，关闭时会等待执行中的查询结束
+func WithDrainDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.drainDelay = d
+	}
+}
+
+// 随配置自动切换的数据源。主从切换、修改密码等导致DSN变化时建立新连接池并替换，
+// 仅修改连接池参数时直接应用到当前连接池。调用方应在每次使用时通过DB()获取连接池
+//
+//	ds, err := gconf_mysql.NewDataSource(gconf.GetCurrentConfigCollection(), "datasource.json")
+//	defer ds.Close()
+//	rows, err := ds.DB().QueryContext(ctx, "select 1")
+type DataSource struct {
+	appId  string
+	key    string
+	role   Role
+	logger *slog.Logger
+
+	pools  *swap.Value[sql.DB]
+	config atomic.Pointer[MySQLDataSourceConfig]
+
+	mux            sync.Mutex // 串行执行reload
+	dsn            string
+	removeListener func()
+}
+
+// 读取集合中key对应的数据源配置并建立连接池，之后监听配置变化
+func NewDataSource(c *gconf.ConfigCollection, key string, opts ...Option) (*DataSource, error) {
+	o := &options{role: RoleMaster, drainDelay: defaultDrainDelay}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	d := &DataSource{
+		appId:  c.AppId(),
+		key:    key,
+		role:   o.role,
+		logger: o.logger.With("component", "gconf_mysql", "appId", c.AppId(), "key", key, "role", o.role),
+	}
+	d.pools = swap.New(o.drainDelay, (*sql.DB).Close, d.logger)
+	value, err := c.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.reload(value); err != nil {
+		return nil, err
+	}
+	d.removeListener = c.AddConfigChangeListener(key, gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		if newValue == "" {
+			d.logger.Warn("datasource removed, keep previous")
+			return
+		}
+		if err := d.reload(newValue); err != nil {
+			d.logger.Warn("invalid datasource, keep previous", "error", err)
+		}
+	}))
+	return d, nil
+}
+
+// 当前的连接池，关闭后返回最后使用的连接池(已关闭)
+func (d *DataSource) DB() *sql.DB {
+	return d.pools.Load()
+}
+
+// 当前生效的数据源配置，调用方不应修改
+func (d *DataSource) Config() *MySQLDataSourceConfig {
+	return d.config.Load()
+}
+
+// 关闭当前及待关闭的连接池，不再响应配置变化
+func (d *DataSource) Close() error {
+	d.removeListener()
+	return d.pools.Close()
+}
+
+func (d *DataSource) reload(value string) error {
+	config, err := ParseDataSourceConfig(value)
+	if err != nil {
+		return &gconf.ConfigError{AppId: d.appId, Key: d.key, Err: err}
+	}
+	config.appId, config.key = d.appId, d.key
+	mysqlServer, err := config.mysqlServerE(d.role == RoleSlave)
+	if err != nil {
+		return err
+	}
+	dsn, err := config.ServerDataSourceName(mysqlServer)
+	if err != nil {
+		return err
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if old := d.pools.Load(); old != nil && dsn == d.dsn {
+		config.Configure(old)
+		d.config.Store(config)
+		return nil
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+	config.Configure(db)
+	replacing := d.pools.Load() != nil
+	if !d.pools.Swap(db) {
+		return db.Close() // 已关闭
+	}
+	d.config.Store(config)
+	d.dsn = dsn
+	if replacing {
+		d.logger.Info("datasource changed, switched to new pool")
+	}
+	return nil
+}
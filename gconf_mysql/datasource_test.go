@@ -0,0 +1,75 @@
+package gconf_mysql
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+func TestDataSourceReload(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	ds, err := NewDataSource(c.GetCurrentConfigCollection(), "datasource.json", WithDrainDelay(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	db := ds.DB()
+
+	// 仅修改连接池参数时不切换
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, `"mysqlServers"`, `"params":{"maxOpenConns":"7"},"mysqlServers"`, 1))
+	if ds.DB() != db {
+		t.Fatal("pool should not be replaced")
+	}
+	if n := db.Stats().MaxOpenConnections; n != 7 {
+		t.Errorf("MaxOpenConnections = %d", n)
+	}
+
+	// 修改密码时切换并关闭旧连接池
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, "123456", "654321", 1))
+	if ds.DB() == db {
+		t.Fatal("pool should be replaced")
+	}
+	if ds.Config().Password != "654321" {
+		t.Errorf("unexpected config %+v", ds.Config())
+	}
+	deadline := time.Now().Add(time.Second)
+	for db.Ping() == nil || !strings.Contains(db.Ping().Error(), "closed") {
+		if time.Now().After(deadline) {
+			t.Fatal("previous pool not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// 无法解析时保留原连接池
+	current := ds.DB()
+	c.SetValue(t, "app", "datasource.json", "{")
+	if ds.DB() != current {
+		t.Error("invalid config should keep previous pool")
+	}
+}
+
+func TestDataSourceClose(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	ds, err := NewDataSource(c.GetCurrentConfigCollection(), "datasource.json", WithRole(RoleSlave))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := ds.DB()
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, "3307", "3308", 1))
+	if ds.DB() == first {
+		t.Fatal("slave pool should be replaced")
+	}
+	ds.Close()
+	for _, db := range []interface{ Ping() error }{first, ds.DB()} {
+		if err := db.Ping(); err == nil || !strings.Contains(err.Error(), "closed") {
+			t.Errorf("pool not closed: %v", err)
+		}
+	}
+	if _, err := NewDataSource(c.GetCurrentConfigCollection(), "missing.json"); err == nil {
+		t.Error("missing key should fail")
+	}
+}
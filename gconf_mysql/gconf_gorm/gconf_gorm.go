@@ -0,0 +1,283 @@
+// Package gconf_gorm 按gconf中的数据源配置建立*gorm.DB，从库通过dbresolver插件负责读查询，
+// 配置变化时自动切换。
+//
+//	db, err := gconf_gorm.New(gconf.GetCurrentConfigCollection(), "datasource.json")
+//	defer db.Close()
+//	db.DB().WithContext(ctx).First(&user)
+package gconf_gorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconf_mysql"
+	"github.com/guanaitong/gconf-go-client/internal/swap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const defaultDrainDelay = 30 * time.Second
+
+type options struct {
+	config      *gorm.Config
+	mysqlConfig mysql.Config
+	policy      dbresolver.Policy
+	logger      *slog.Logger
+	drainDelay  time.Duration
+}
+
+type Option func(*options)
+
+// 指定gorm的配置。每次建立*gorm.DB时使用其副本，调用方不应再将其用于其他gorm.Open
+func WithConfig(config *gorm.Config) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// 指定gorm mysql驱动的配置，其中的DSN及Conn会被忽略
+func WithMySQLConfig(config mysql.Config) Option {
+	return func(o *options) {
+		o.mysqlConfig = config
+	}
+}
+
+// 指定从库的负载均衡策略，默认为dbresolver.RandomPolicy
+func WithPolicy(policy dbresolver.Policy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// 指定日志输出，默认使用slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 切换后等待多久关闭旧的连接池，默认30s
+func WithDrainDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.drainDelay = d
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{drainDelay: defaultDrainDelay}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.config == nil {
+		o.config = &gorm.Config{}
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	return o
+}
+
+// 按数据源配置建立的*gorm.DB及其连接池
+type instance struct {
+	db    *gorm.DB
+	pools []*sql.DB // 主库在前，从库按配置顺序
+	dsns  []string
+}
+
+func (i *instance) close() error {
+	var errs []error
+	for _, p := range i.pools {
+		errs = append(errs, p.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// 按数据源配置建立*gorm.DB，主库为默认连接，配置了从库时注册dbresolver由从库负责读查询。
+// 不随配置变化且无法关闭从库的连接池，需要自动切换或关闭时使用New
+func Open(config *gconf_mysql.MySQLDataSourceConfig, opts ...Option) (*gorm.DB, error) {
+	i, err := open(config, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return i.db, nil
+}
+
+func open(config *gconf_mysql.MySQLDataSourceConfig, o *options) (*instance, error) {
+	i := &instance{}
+	if err := i.open(config, o); err != nil {
+		i.close()
+		return nil, err
+	}
+	return i, nil
+}
+
+func (i *instance) open(config *gconf_mysql.MySQLDataSourceConfig, o *options) error {
+	master, slaves, err := config.Servers()
+	if err != nil {
+		return err
+	}
+	var dialectors []gorm.Dialector
+	for _, ms := range append([]*gconf_mysql.MysqlServer{master}, slaves...) {
+		dsn, err := config.ServerDataSourceName(ms)
+		if err != nil {
+			return err
+		}
+		pool, err := config.OpenServer(ms)
+		if err != nil {
+			return err
+		}
+		i.pools = append(i.pools, pool)
+		i.dsns = append(i.dsns, dsn)
+		mysqlConfig := o.mysqlConfig
+		mysqlConfig.DSN, mysqlConfig.Conn = "", pool
+		dialectors = append(dialectors, mysql.New(mysqlConfig))
+	}
+
+	gormConfig := *o.config
+	gormConfig.Plugins = maps.Clone(o.config.Plugins)
+	if i.db, err = gorm.Open(dialectors[0], &gormConfig); err != nil {
+		return err
+	}
+	if len(dialectors) > 1 {
+		return i.db.Use(dbresolver.Register(dbresolver.Config{Replicas: dialectors[1:], Policy: o.policy}))
+	}
+	return nil
+}
+
+// 随配置自动切换的*gorm.DB。服务器或连接参数变化时建立新的*gorm.DB并替换，
+// 仅修改连接池参数时直接应用到当前连接池。建立*gorm.DB可能访问数据库，
+// 因此在单独的协程中处理配置变化，不阻塞配置通知。调用方应在每次使用时通过DB()获取
+type DB struct {
+	o      *options
+	logger *slog.Logger
+
+	instances *swap.Value[instance]
+	pending   chan string // 待处理的最新配置
+
+	cancel         context.CancelFunc
+	done           chan struct{}
+	removeListener func()
+}
+
+// 读取集合中key对应的数据源配置并建立*gorm.DB，之后监听配置变化
+func New(c *gconf.ConfigCollection, key string, opts ...Option) (*DB, error) {
+	o := newOptions(opts)
+	d := &DB{
+		o:       o,
+		logger:  o.logger.With("component", "gconf_gorm", "appId", c.AppId(), "key", key),
+		pending: make(chan string, 1),
+		done:    make(chan struct{}),
+	}
+	d.instances = swap.New(o.drainDelay, (*instance).close, d.logger)
+	value, err := c.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.reload(value); err != nil {
+		return nil, fmt.Errorf("gconf_gorm: open %s of %s: %w", key, c.AppId(), err)
+	}
+	var ctx context.Context
+	ctx, d.cancel = context.WithCancel(context.Background())
+	go d.watch(ctx)
+	d.removeListener = c.AddConfigChangeListener(key, gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		if newValue == "" {
+			d.logger.Warn("datasource removed, keep previous")
+			return
+		}
+		d.enqueue(newValue)
+	}))
+	return d, nil
+}
+
+// 当前的*gorm.DB
+func (d *DB) DB() *gorm.DB {
+	return d.instances.Load().db
+}
+
+// 关闭当前及待关闭的连接池，不再响应配置变化
+func (d *DB) Close() error {
+	d.removeListener()
+	d.cancel()
+	<-d.done
+	return d.instances.Close()
+}
+
+// 记录最新的配置，尚未处理的旧配置直接丢弃
+func (d *DB) enqueue(value string) {
+	for {
+		select {
+		case d.pending <- value:
+			return
+		default:
+		}
+		select {
+		case <-d.pending:
+		default:
+		}
+	}
+}
+
+func (d *DB) watch(ctx context.Context) {
+	defer close(d.done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case value := <-d.pending:
+			if err := d.reload(value); err != nil {
+				d.logger.Warn("reload datasource failed, keep previous", "error", err)
+			}
+		}
+	}
+}
+
+// 按配置切换*gorm.DB，仅在New及watch中串行调用
+func (d *DB) reload(value string) error {
+	config, err := gconf_mysql.ParseDataSourceConfig(value)
+	if err != nil {
+		return err
+	}
+	old := d.instances.Load()
+	if old != nil && slices.Equal(dsnsOf(config), old.dsns) {
+		for _, p := range old.pools {
+			config.Configure(p)
+		}
+		return nil
+	}
+	i, err := open(config, d.o)
+	if err != nil {
+		return err
+	}
+	if !d.instances.Swap(i) {
+		return i.close() // 已关闭
+	}
+	if old != nil {
+		d.logger.Info("datasource changed, switched to new pools", "servers", len(i.pools), "drainDelay", d.o.drainDelay)
+	}
+	return nil
+}
+
+// 配置中主库及从库的DSN，无法生成时返回nil
+func dsnsOf(config *gconf_mysql.MySQLDataSourceConfig) []string {
+	master, slaves, err := config.Servers()
+	if err != nil {
+		return nil
+	}
+	var res []string
+	for _, ms := range append([]*gconf_mysql.MysqlServer{master}, slaves...) {
+		dsn, err := config.ServerDataSourceName(ms)
+		if err != nil {
+			return nil
+		}
+		res = append(res, dsn)
+	}
+	return res
+}
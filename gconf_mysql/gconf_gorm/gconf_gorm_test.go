@@ -0,0 +1,133 @@
+package gconf_gorm
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client/gconf_mysql"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const testDataSource = `{"dbName":"ucenter","username":"root","password":"123456","mysqlServers":[
+	{"name":"m","ip":"127.0.0.1","port":"3306","role":"master"},
+	{"name":"s1","ip":"127.0.0.1","port":"3307","role":"slave"},
+	{"name":"s2","ip":"127.0.0.1","port":"3308","role":"slave"}]}`
+
+// 测试环境没有数据库，不连接服务器
+func offline() []Option {
+	return []Option{
+		WithConfig(&gorm.Config{DisableAutomaticPing: true}),
+		WithMySQLConfig(mysql.Config{SkipInitializeWithVersion: true}),
+		WithPolicy(dbresolver.StrictRoundRobinPolicy()),
+	}
+}
+
+func connPoolOf(db *gorm.DB, write bool) gorm.ConnPool {
+	tx := db.Session(&gorm.Session{DryRun: true})
+	if write {
+		tx = tx.Clauses(dbresolver.Write)
+	}
+	var rows []map[string]any
+	return tx.Table("users").Find(&rows).Statement.ConnPool
+}
+
+func TestOpen(t *testing.T) {
+	config, err := gconf_mysql.ParseDataSourceConfig(testDataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := open(config, newOptions(offline()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.close()
+	if len(i.pools) != 3 || !strings.Contains(i.dsns[2], "127.0.0.1:3308") {
+		t.Fatalf("unexpected pools %v", i.dsns)
+	}
+	if p := connPoolOf(i.db, true); p != i.pools[0] {
+		t.Errorf("write should use master, got %v", p)
+	}
+	reads := map[gorm.ConnPool]bool{}
+	for n := 0; n < 4; n++ {
+		reads[connPoolOf(i.db, false)] = true
+	}
+	if len(reads) != 2 || reads[i.pools[0]] {
+		t.Errorf("reads should be spread over slaves, got %d pools", len(reads))
+	}
+
+	if _, err := Open(&gconf_mysql.MySQLDataSourceConfig{}, offline()...); err == nil {
+		t.Error("config without servers should fail")
+	}
+}
+
+// 配置变化在单独的协程中处理，等待cond成立
+func waitFor(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReload(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	d, err := New(c.GetCurrentConfigCollection(), "datasource.json", append(offline(), WithDrainDelay(time.Millisecond))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	first := d.instances.Load()
+
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, `"mysqlServers"`, `"params":{"maxOpenConns":"9"},"mysqlServers"`, 1))
+	waitFor(t, func() bool { return first.pools[1].Stats().MaxOpenConnections == 9 }, "pool params not applied")
+	if d.instances.Load() != first {
+		t.Fatal("pool params change should not rebuild")
+	}
+
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, "3308", "3309", 1))
+	waitFor(t, func() bool { return d.instances.Load() != first }, "server change should rebuild")
+	second := d.instances.Load()
+	if d.DB() != second.db {
+		t.Fatal("DB should follow the new instance")
+	}
+	waitFor(t, func() bool {
+		err := first.pools[0].Ping()
+		return err != nil && strings.Contains(err.Error(), "closed")
+	}, "previous pools not closed")
+
+	if err := d.reload(`{"mysqlServers":[]}`); err == nil || d.instances.Load() != second {
+		t.Error("invalid config should keep previous")
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	d, err := New(c.GetCurrentConfigCollection(), "datasource.json", append(offline(), WithDrainDelay(time.Hour))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := d.instances.Load()
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, "3308", "3309", 1))
+	waitFor(t, func() bool { return d.instances.Load() != first }, "server change should rebuild")
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range append(first.pools, d.instances.Load().pools...) {
+		if err := p.Ping(); err == nil || !strings.Contains(err.Error(), "closed") {
+			t.Errorf("pool not closed: %v", err)
+		}
+	}
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	if d.DB() != d.instances.Load().db || d.instances.Load().dsns[2] == first.dsns[2] {
+		t.Error("closed DB should not reload")
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/guanaitong/gconf-go-client/gconf_mysql/gconf_gorm
+
+go 1.21
+
+require (
+	github.com/guanaitong/gconf-go-client v0.0.0-00010101000000-000000000000
+	gorm.io/driver/mysql v1.6.0
+	gorm.io/gorm v1.31.2
+	gorm.io/plugin/dbresolver v1.6.2
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/go-sql-driver/mysql v1.8.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
+
+replace github.com/guanaitong/gconf-go-client => ../..
@@ -3,12 +3,13 @@ package gconf_mysql
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/guanaitong/gconf-go-client"
 )
 
@@ -22,6 +23,15 @@ type MysqlServer struct {
 	Version   string `json:"version"`
 	GroupName string `json:"groupName"`
 	Role      string `json:"role"`
+	Weight    int    `json:"weight"` // 从库的负载均衡权重，默认为1
+}
+
+func (ms *MysqlServer) addr() string {
+	host := ms.Domain
+	if host == "" {
+		host = ms.Ip
+	}
+	return host + ":" + ms.Port
 }
 
 type MySQLDataSourceConfig struct {
@@ -32,6 +42,13 @@ type MySQLDataSourceConfig struct {
 	GroupName         string            `json:"groupName"`
 	MysqlServers      []*MysqlServer    `json:"mysqlServers"`
 	Params            map[string]string `json:"params"`
+
+	appId, key string // 通过GetMySQLDataSourceConfigE读取时的来源，用于错误信息
+}
+
+// 字段field相关的错误
+func (dataSourceConfig *MySQLDataSourceConfig) fieldError(field string, err error) error {
+	return &gconf.ConfigError{AppId: dataSourceConfig.appId, Key: dataSourceConfig.key, Field: field, Err: err}
 }
 
 func (dataSourceConfig *MySQLDataSourceConfig) OpenMaster() (db *sql.DB, err error) {
@@ -43,13 +60,46 @@ func (dataSourceConfig *MySQLDataSourceConfig) OpenSlave() (db *sql.DB, err erro
 }
 
 func (dataSourceConfig *MySQLDataSourceConfig) open(preferSlave bool) (db *sql.DB, err error) {
-	db, err = sql.Open("mysql", dataSourceConfig.dataSourceName(preferSlave))
-	if err == nil {
-		db.SetMaxOpenConns(dataSourceConfig.getParamValue("maxOpenConns", 20))
-		db.SetMaxIdleConns(dataSourceConfig.getParamValue("maxIdleConns", 3))
-		db.SetConnMaxLifetime(time.Second * time.Duration(dataSourceConfig.getParamValue("maxIdleConns", 1200)))
+	mysqlServer, err := dataSourceConfig.mysqlServerE(preferSlave)
+	if err != nil {
+		return nil, err
 	}
-	return
+	return dataSourceConfig.OpenServer(mysqlServer)
+}
+
+// 建立连接指定服务器的连接池，并按Params设置连接池参数
+func (dataSourceConfig *MySQLDataSourceConfig) OpenServer(mysqlServer *MysqlServer) (*sql.DB, error) {
+	dsn, err := dataSourceConfig.ServerDataSourceName(mysqlServer)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	dataSourceConfig.Configure(db)
+	return db, nil
+}
+
+// Params中的连接池参数，不写入DSN：
+//
+//	maxOpenConns     最大连接数，默认20
+//	maxIdleConns     最大空闲连接数，默认3
+//	connMaxLifetime  连接最长使用时间，如20m，纯数字时单位为秒，默认1200
+//	connMaxIdleTime  连接最长空闲时间，格式同connMaxLifetime，默认不限制
+var poolParams = map[string]bool{
+	"maxOpenConns":    true,
+	"maxIdleConns":    true,
+	"connMaxLifetime": true,
+	"connMaxIdleTime": true,
+}
+
+// 按Params设置连接池参数，可用于配置变化后调整已有的连接池
+func (dataSourceConfig *MySQLDataSourceConfig) Configure(db *sql.DB) {
+	db.SetMaxOpenConns(dataSourceConfig.getParamValue("maxOpenConns", 20))
+	db.SetMaxIdleConns(dataSourceConfig.getParamValue("maxIdleConns", 3))
+	db.SetConnMaxLifetime(dataSourceConfig.getDurationParam("connMaxLifetime", 1200*time.Second))
+	db.SetConnMaxIdleTime(dataSourceConfig.getDurationParam("connMaxIdleTime", 0))
 }
 
 func (dataSourceConfig *MySQLDataSourceConfig) MasterDataSourceName() string {
@@ -60,27 +110,76 @@ func (dataSourceConfig *MySQLDataSourceConfig) SlaveDataSourceName() string {
 	return dataSourceConfig.dataSourceName(true)
 }
 
+// 同MasterDataSourceName，没有服务器或Params无效时返回错误
+func (dataSourceConfig *MySQLDataSourceConfig) MasterDataSourceNameE() (string, error) {
+	return dataSourceConfig.dataSourceNameE(false)
+}
+
+// 同SlaveDataSourceName，没有服务器或Params无效时返回错误
+func (dataSourceConfig *MySQLDataSourceConfig) SlaveDataSourceNameE() (string, error) {
+	return dataSourceConfig.dataSourceNameE(true)
+}
+
 func (dataSourceConfig *MySQLDataSourceConfig) dataSourceName(preferSlave bool) string {
+	dsn, err := dataSourceConfig.dataSourceNameE(preferSlave)
+	if err != nil {
+		panic(err.Error())
+	}
+	return dsn
+}
+
+func (dataSourceConfig *MySQLDataSourceConfig) dataSourceNameE(preferSlave bool) (string, error) {
+	mysqlServer, err := dataSourceConfig.mysqlServerE(preferSlave)
+	if err != nil {
+		return "", err
+	}
+	return dataSourceConfig.ServerDataSourceName(mysqlServer)
+}
+
+// 未在Params中指定时使用的驱动参数
+var defaultDriverParams = map[string]string{
+	"charset":   "utf8mb4",
+	"parseTime": "true",
+	"loc":       "Local",
+	"time_zone": "'+8:00'",
+}
+
+// 连接mysqlServer的DSN，由mysql.Config.FormatDSN生成，用户名、密码及库名中的特殊字符不影响解析。
+// Params中除连接池参数外的参数均作为驱动参数，如timeout、readTimeout、tls、collation及time_zone等系统变量，
+// 可覆盖defaultDriverParams
+func (dataSourceConfig *MySQLDataSourceConfig) ServerDataSourceName(mysqlServer *MysqlServer) (string, error) {
+	config, err := dataSourceConfig.driverConfig(mysqlServer)
+	if err != nil {
+		return "", err
+	}
+	return config.FormatDSN(), nil
+}
+
+func (dataSourceConfig *MySQLDataSourceConfig) driverConfig(mysqlServer *MysqlServer) (*mysql.Config, error) {
+	query := url.Values{}
+	for k, v := range defaultDriverParams {
+		query.Set(k, v)
+	}
+	for k, v := range dataSourceConfig.Params {
+		if !poolParams[k] {
+			query.Set(k, v)
+		}
+	}
+	// 由驱动解析参数，与直接使用DSN时的行为一致
+	config, err := mysql.ParseDSN("/?" + query.Encode())
+	if err != nil {
+		return nil, dataSourceConfig.fieldError("params", err)
+	}
 	var pwd = gconf.Decrypt(dataSourceConfig.EncryptedPassword)
 	if pwd == "" {
 		pwd = dataSourceConfig.Password
 	}
-	mysqlServer := dataSourceConfig.getMysqlServer(preferSlave)
-	if mysqlServer == nil {
-		panic("there is no mysql server")
-	}
-	var host = mysqlServer.Domain
-	if host == "" {
-		host = mysqlServer.Ip
-	}
-	timezone := "'+8:00'"
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-		dataSourceConfig.Username,
-		pwd,
-		host,
-		mysqlServer.Port,
-		dataSourceConfig.DbName,
-	) + "?charset=utf8mb4&parseTime=true&loc=Local&time_zone=" + url.QueryEscape(timezone)
+	config.User = dataSourceConfig.Username
+	config.Passwd = pwd
+	config.Net = "tcp"
+	config.Addr = mysqlServer.addr()
+	config.DBName = dataSourceConfig.DbName
+	return config, nil
 }
 
 func (dataSourceConfig *MySQLDataSourceConfig) getParamValue(key string, defaultValue int) int {
@@ -94,6 +193,47 @@ func (dataSourceConfig *MySQLDataSourceConfig) getParamValue(key string, default
 	return defaultValue
 }
 
+func (dataSourceConfig *MySQLDataSourceConfig) getDurationParam(key string, defaultValue time.Duration) time.Duration {
+	v, ok := dataSourceConfig.Params[key]
+	if !ok {
+		return defaultValue
+	}
+	if i, err := strconv.Atoi(v); err == nil {
+		return time.Duration(i) * time.Second
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return d
+	}
+	return defaultValue
+}
+
+// 主库及所有从库，按配置顺序排列。只有一个服务器时将其作为主库
+func (dataSourceConfig *MySQLDataSourceConfig) Servers() (master *MysqlServer, slaves []*MysqlServer, err error) {
+	if len(dataSourceConfig.MysqlServers) == 1 {
+		return dataSourceConfig.MysqlServers[0], nil, nil
+	}
+	for _, ms := range dataSourceConfig.MysqlServers {
+		switch strings.ToLower(ms.Role) {
+		case string(RoleMaster):
+			master = ms
+		case string(RoleSlave):
+			slaves = append(slaves, ms)
+		}
+	}
+	if master == nil {
+		return nil, nil, dataSourceConfig.fieldError("mysqlServers", errors.New("there is no mysql master"))
+	}
+	return master, slaves, nil
+}
+
+func (dataSourceConfig *MySQLDataSourceConfig) mysqlServerE(preferSlave bool) (*MysqlServer, error) {
+	mysqlServer := dataSourceConfig.getMysqlServer(preferSlave)
+	if mysqlServer == nil {
+		return nil, dataSourceConfig.fieldError("mysqlServers", errors.New("there is no mysql server"))
+	}
+	return mysqlServer, nil
+}
+
 func (dataSourceConfig *MySQLDataSourceConfig) getMysqlServer(preferSlave bool) *MysqlServer {
 	size := len(dataSourceConfig.MysqlServers)
 	if size == 1 {
@@ -118,19 +258,44 @@ func (dataSourceConfig *MySQLDataSourceConfig) getMysqlServer(preferSlave bool)
 }
 
 func GetMySQLDataSourceConfig(key string) *MySQLDataSourceConfig {
+	dataSourceConfig, err := GetMySQLDataSourceConfigE(key)
+	if err != nil {
+		panic(err.Error())
+	}
+	return dataSourceConfig
+}
+
+// 同GetMySQLDataSourceConfig，key为空、不存在或无法解析时返回错误
+func GetMySQLDataSourceConfigE(key string) (*MySQLDataSourceConfig, error) {
 	if key == "" {
-		panic("mysql datasource key is empty")
+		return nil, errors.New("mysql datasource key is empty")
 	}
+	configValue, err := gconf.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	dataSourceConfig, err := ParseDataSourceConfig(configValue)
+	if err != nil {
+		return nil, &gconf.ConfigError{AppId: gconf.Default().AppId(), Key: key, Err: err}
+	}
+	dataSourceConfig.appId, dataSourceConfig.key = gconf.Default().AppId(), key
+	return dataSourceConfig, nil
+}
 
+// 解析datasource.json格式的配置
+func ParseDataSourceConfig(value string) (*MySQLDataSourceConfig, error) {
 	dataSourceConfig := new(MySQLDataSourceConfig)
-	configValue := gconf.GetCurrentConfigCollection().GetValue(key).Raw()
-	err := json.Unmarshal([]byte(configValue), dataSourceConfig)
-	if err != nil {
-		panic(err.Error())
+	if err := json.Unmarshal([]byte(value), dataSourceConfig); err != nil {
+		return nil, err
 	}
-	return dataSourceConfig
+	return dataSourceConfig, nil
 }
 
 func GetDefaultMySQLDataSourceConfig() *MySQLDataSourceConfig {
 	return GetMySQLDataSourceConfig(defaultMySQLConfigKey)
 }
+
+// 同GetDefaultMySQLDataSourceConfig，读取失败时返回错误
+func GetDefaultMySQLDataSourceConfigE() (*MySQLDataSourceConfig, error) {
+	return GetMySQLDataSourceConfigE(defaultMySQLConfigKey)
+}
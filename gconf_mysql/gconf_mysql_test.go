@@ -1,14 +1,32 @@
 package gconf_mysql
 
 import (
+	"errors"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
 )
 
+// 设置GCONF_TEST_MYSQL_DATASOURCE为datasource.json的内容时，连接真实数据库测试
+const testDataSource = `{"dbName":"ucenter","username":"root","password":"123456","mysqlServers":[
+	{"name":"m","ip":"127.0.0.1","port":"3306","role":"master"},
+	{"name":"s","domain":"slave.local","port":"3307","role":"slave"}]}`
+
 func TestGetDataSourceConfig(t *testing.T) {
-	gconf.Init("gce-api-ucenter")
+	s := gconftest.NewServer()
+	defer s.Close()
+	dataSource, integration := os.LookupEnv("GCONF_TEST_MYSQL_DATASOURCE")
+	if !integration {
+		dataSource = testDataSource
+	}
+	s.SetCollection("gce-api-ucenter", map[string]string{"datasource.json": dataSource})
+	gconf.Init("gce-api-ucenter", gconf.WithEndpoints(s.URL))
+
 	d := GetDefaultMySQLDataSourceConfig()
 	d2 := GetMySQLDataSourceConfig("datasource.json")
 	if d == nil {
@@ -20,20 +38,144 @@ func TestGetDataSourceConfig(t *testing.T) {
 
 	m := d.MasterDataSourceName()
 
-	s := d.SlaveDataSourceName()
+	s2 := d.SlaveDataSourceName()
 
-	if m == "" || s == "" {
+	if m == "" || s2 == "" {
 		t.Error("m or s is not nil")
 	}
+	if !integration {
+		if m != "root:123456@tcp(127.0.0.1:3306)/ucenter?loc=Local&parseTime=true&charset=utf8mb4&time_zone=%27%2B8%3A00%27" {
+			t.Errorf("unexpected master dsn %s", m)
+		}
+		if s2 != "root:123456@tcp(slave.local:3307)/ucenter?loc=Local&parseTime=true&charset=utf8mb4&time_zone=%27%2B8%3A00%27" {
+			t.Errorf("unexpected slave dsn %s", s2)
+		}
+	}
 	db, err := d.OpenMaster()
 	if err != nil {
 		t.Error(err)
 	}
 	t.Log(db)
 
+	if !integration {
+		return
+	}
 	err = db.Ping()
 	if err != nil {
 		t.Error(err)
 	}
 	//db.Exec()
 }
+
+func TestDataSourceName(t *testing.T) {
+	d := &MySQLDataSourceConfig{
+		DbName:   "ucenter",
+		Username: "root",
+		Password: "p@ss:w/rd?&",
+		MysqlServers: []*MysqlServer{
+			{Name: "m", Ip: "127.0.0.1", Port: "3306", Role: "master"},
+		},
+		Params: map[string]string{
+			"loc":             "Local",
+			"time_zone":       "'+8:00'",
+			"timeout":         "3s",
+			"collation":       "utf8mb4_unicode_ci",
+			"parseTime":       "false",
+			"maxOpenConns":    "5",
+			"connMaxLifetime": "20m",
+			"connMaxIdleTime": "90",
+		},
+	}
+	dsn := d.MasterDataSourceName()
+	if strings.Contains(dsn, "maxOpenConns") || strings.Contains(dsn, "connMax") {
+		t.Errorf("pool params should not be in dsn %s", dsn)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Passwd != d.Password || cfg.Addr != "127.0.0.1:3306" || cfg.DBName != "ucenter" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.Loc != time.Local || cfg.Timeout != 3*time.Second || cfg.Collation != "utf8mb4_unicode_ci" || cfg.ParseTime {
+		t.Errorf("params not applied: %+v", cfg)
+	}
+	if cfg.Params["time_zone"] != "'+8:00'" || cfg.Params["charset"] != "utf8mb4" {
+		t.Errorf("unexpected params %v", cfg.Params)
+	}
+
+	db, err := d.OpenMaster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n := db.Stats().MaxOpenConnections; n != 5 {
+		t.Errorf("MaxOpenConnections = %d", n)
+	}
+	if v := d.getDurationParam("connMaxLifetime", 0); v != 20*time.Minute {
+		t.Errorf("connMaxLifetime = %s", v)
+	}
+	if v := d.getDurationParam("connMaxIdleTime", 0); v != 90*time.Second {
+		t.Errorf("connMaxIdleTime = %s", v)
+	}
+
+	d.DbName, d.Password = "uc/enter?x", "p/w?d@1"
+	d.Params = nil
+	cfg, err = mysql.ParseDSN(d.MasterDataSourceName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Passwd != d.Password || cfg.DBName != d.DbName || cfg.Params["charset"] != "utf8mb4" {
+		t.Errorf("special characters not escaped: %+v", cfg)
+	}
+
+	d.Params = map[string]string{"timeout": "soon"}
+	if _, err := d.OpenMaster(); err == nil {
+		t.Error("invalid driver param should fail")
+	}
+}
+
+func TestGetDataSourceConfigE(t *testing.T) {
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("mysql-e", map[string]string{
+		"bad.json":      `{"mysqlServers":{}}`,
+		"empty.json":    `{"dbName":"ucenter"}`,
+		"params.json":   `{"params":{"readTimeout":"soon"},"mysqlServers":[{"ip":"127.0.0.1","port":"3306"}]}`,
+		"nomaster.json": `{"mysqlServers":[{"ip":"a","role":"slave"},{"ip":"b","role":"slave"}]}`,
+	})
+	gconf.Init("mysql-e", gconf.WithEndpoints(s.URL))
+
+	if _, err := GetMySQLDataSourceConfigE(""); err == nil {
+		t.Error("empty key should fail")
+	}
+	if _, err := GetDefaultMySQLDataSourceConfigE(); !errors.Is(err, gconf.ErrNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+	var ce *gconf.ConfigError
+	if _, err := GetMySQLDataSourceConfigE("bad.json"); !errors.As(err, &ce) || ce.AppId != "mysql-e" || ce.Key != "bad.json" {
+		t.Errorf("unexpected error %v", err)
+	}
+	for key, field := range map[string]string{"empty.json": "mysqlServers", "params.json": "params"} {
+		d, err := GetMySQLDataSourceConfigE(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.MasterDataSourceNameE(); !errors.As(err, &ce) || ce.Key != key || ce.Field != field {
+			t.Errorf("%s: unexpected error %v", key, err)
+		}
+		if _, err := d.OpenSlave(); !errors.As(err, &ce) || ce.Field != field {
+			t.Errorf("%s: unexpected error %v", key, err)
+		}
+	}
+	d, _ := GetMySQLDataSourceConfigE("nomaster.json")
+	if _, _, err := d.Servers(); !errors.As(err, &ce) || ce.Field != "mysqlServers" {
+		t.Errorf("unexpected error %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MasterDataSourceName should panic")
+		}
+	}()
+	d.MasterDataSourceName()
+}
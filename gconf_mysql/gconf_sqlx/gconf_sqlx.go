@@ -0,0 +1,68 @@
+// Package gconf_sqlx 按gconf中的数据源配置建立*sqlx.DB，配置变化时自动切换。
+//
+//	db, err := gconf_sqlx.New(gconf.GetCurrentConfigCollection(), "datasource.json")
+//	defer db.Close()
+//	err = db.DB().GetContext(ctx, &user, "select * from user where id = ?", id)
+package gconf_sqlx
+
+import (
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconf_mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+const driverName = "mysql"
+
+// 按数据源配置建立连接指定角色的*sqlx.DB，不随配置变化
+func Open(config *gconf_mysql.MySQLDataSourceConfig, role gconf_mysql.Role) (*sqlx.DB, error) {
+	var db *sql.DB
+	var err error
+	if role == gconf_mysql.RoleSlave {
+		db, err = config.OpenSlave()
+	} else {
+		db, err = config.OpenMaster()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sqlx.NewDb(db, driverName), nil
+}
+
+// 随配置自动切换的*sqlx.DB，切换规则见gconf_mysql.DataSource。调用方应在每次使用时通过DB()获取
+type DB struct {
+	source *gconf_mysql.DataSource
+	cached atomic.Pointer[sqlx.DB]
+}
+
+// 读取集合中key对应的数据源配置并建立连接池，之后监听配置变化，opts见gconf_mysql.NewDataSource
+func New(c *gconf.ConfigCollection, key string, opts ...gconf_mysql.Option) (*DB, error) {
+	source, err := gconf_mysql.NewDataSource(c, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{source: source}, nil
+}
+
+// 当前的*sqlx.DB
+func (d *DB) DB() *sqlx.DB {
+	db := d.source.DB()
+	if x := d.cached.Load(); x != nil && x.DB == db {
+		return x
+	}
+	x := sqlx.NewDb(db, driverName)
+	d.cached.Store(x)
+	return x
+}
+
+// 底层的数据源
+func (d *DB) DataSource() *gconf_mysql.DataSource {
+	return d.source
+}
+
+// 关闭数据源
+func (d *DB) Close() error {
+	return d.source.Close()
+}
@@ -0,0 +1,49 @@
+package gconf_sqlx
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/guanaitong/gconf-go-client/gconf_mysql"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+const testDataSource = `{"dbName":"ucenter","username":"root","password":"123456","mysqlServers":[
+	{"name":"m","ip":"127.0.0.1","port":"3306","role":"master"},
+	{"name":"s","ip":"127.0.0.1","port":"3307","role":"slave"}]}`
+
+func TestOpen(t *testing.T) {
+	config, err := gconf_mysql.ParseDataSourceConfig(testDataSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(config, gconf_mysql.RoleSlave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.DriverName() != "mysql" || db.Rebind("select ?") != "select ?" {
+		t.Errorf("unexpected driver %s", db.DriverName())
+	}
+}
+
+func TestReload(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	d, err := New(c.GetCurrentConfigCollection(), "datasource.json", gconf_mysql.WithDrainDelay(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	first := d.DB()
+	if d.DB() != first || first.DB != d.DataSource().DB() {
+		t.Fatal("sqlx.DB should be cached")
+	}
+	c.SetValue(t, "app", "datasource.json", strings.Replace(testDataSource, "123456", "654321", 1))
+	second := d.DB()
+	if second == first || second.DB != d.DataSource().DB() {
+		t.Error("sqlx.DB should follow the new pool")
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/guanaitong/gconf-go-client/gconf_mysql/gconf_sqlx
+
+go 1.21
+
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/guanaitong/gconf-go-client v0.0.0-00010101000000-000000000000
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/jmoiron/sqlx v1.4.0
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+)
+
+replace github.com/guanaitong/gconf-go-client => ../..
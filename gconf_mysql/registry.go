@@ -0,0 +1,105 @@
+package gconf_mysql
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+var ErrRegistryClosed = errors.New("gconf_mysql: registry closed")
+
+type registryKey struct {
+	name string
+	role Role
+}
+
+// 多数据源注册表，按配置key及角色在首次使用时建立并缓存DataSource，随配置自动切换
+//
+//	r := gconf_mysql.NewRegistry(gconf.GetCurrentConfigCollection())
+//	defer r.Close()
+//	db, err := r.DB("order-datasource.json", gconf_mysql.RoleSlave)
+type Registry struct {
+	collection *gconf.ConfigCollection
+	opts       []Option
+
+	mux     sync.Mutex
+	sources map[registryKey]*DataSource
+	closed  bool
+}
+
+// 创建注册表，opts应用于所有数据源，其中的WithRole会被DB及DataSource的role覆盖
+func NewRegistry(c *gconf.ConfigCollection, opts ...Option) *Registry {
+	return &Registry{collection: c, opts: opts, sources: map[registryKey]*DataSource{}}
+}
+
+// 返回name对应角色的数据源，首次调用时建立连接池。role为空时为RoleMaster，
+// 为RoleSlave时固定连接最后一个从库，见RoleSlave
+func (r *Registry) DataSource(name string, role Role) (*DataSource, error) {
+	if role == "" {
+		role = RoleMaster
+	}
+	k := registryKey{name: name, role: role}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.closed {
+		return nil, ErrRegistryClosed
+	}
+	if d, ok := r.sources[k]; ok {
+		return d, nil
+	}
+	d, err := NewDataSource(r.collection, name, append(append([]Option{}, r.opts...), WithRole(role))...)
+	if err != nil {
+		return nil, err
+	}
+	r.sources[k] = d
+	return d, nil
+}
+
+// 返回name对应角色当前的连接池，见DataSource
+func (r *Registry) DB(name string, role Role) (*sql.DB, error) {
+	d, err := r.DataSource(name, role)
+	if err != nil {
+		return nil, err
+	}
+	return d.DB(), nil
+}
+
+// 连接池统计，见Registry.Stats
+type PoolStats struct {
+	Name string
+	Role Role
+	sql.DBStats
+}
+
+// 已建立的各连接池的统计，按name、role排序
+func (r *Registry) Stats() []PoolStats {
+	r.mux.Lock()
+	res := make([]PoolStats, 0, len(r.sources))
+	for k, d := range r.sources {
+		res = append(res, PoolStats{Name: k.name, Role: k.role, DBStats: d.DB().Stats()})
+	}
+	r.mux.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return res[i].Role < res[j].Role
+	})
+	return res
+}
+
+// 关闭所有数据源，之后DB及DataSource返回ErrRegistryClosed
+func (r *Registry) Close() error {
+	r.mux.Lock()
+	sources := r.sources
+	r.sources, r.closed = nil, true
+	r.mux.Unlock()
+	var errs []error
+	for _, d := range sources {
+		errs = append(errs, d.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package gconf_mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+func TestRegistry(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", testDataSource)
+	c.SetValue(t, "app", "order.json", testDataSource)
+	r := NewRegistry(c.GetCurrentConfigCollection())
+
+	master, err := r.DB("datasource.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := r.DB("datasource.json", RoleMaster); again != master {
+		t.Error("pool should be cached")
+	}
+	slave, err := r.DB("datasource.json", RoleSlave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if slave == master {
+		t.Error("slave should have its own pool")
+	}
+	d, _ := r.DataSource("datasource.json", RoleSlave)
+	if d.role != RoleSlave {
+		t.Errorf("role = %s", d.role)
+	}
+	if _, err := r.DB("order.json", RoleMaster); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.DB("missing.json", RoleMaster); err == nil {
+		t.Error("missing key should fail")
+	}
+
+	stats := r.Stats()
+	if len(stats) != 3 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats[0].Name != "datasource.json" || stats[0].Role != RoleMaster || stats[1].Role != RoleSlave || stats[2].Name != "order.json" {
+		t.Errorf("unexpected order %+v", stats)
+	}
+	if stats[0].MaxOpenConnections != 20 {
+		t.Errorf("MaxOpenConnections = %d", stats[0].MaxOpenConnections)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := master.Ping(); err == nil {
+		t.Error("pool should be closed")
+	}
+	if _, err := r.DB("datasource.json", RoleMaster); !errors.Is(err, ErrRegistryClosed) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRegistryTwoSlaves(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "datasource.json", `{"dbName":"ucenter","username":"root","password":"123456","mysqlServers":[
+		{"name":"s1","ip":"127.0.0.1","port":"3307","role":"slave"},
+		{"name":"m","ip":"127.0.0.1","port":"3306","role":"master"},
+		{"name":"s2","ip":"127.0.0.1","port":"3308","role":"slave"}]}`)
+	r := NewRegistry(c.GetCurrentConfigCollection())
+	defer r.Close()
+
+	d, err := r.DataSource("datasource.json", RoleSlave)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(d.dsn, "@tcp(127.0.0.1:3308)/") {
+		t.Errorf("slave datasource should pin the last slave, dsn %s", d.dsn)
+	}
+	if again, _ := r.DataSource("datasource.json", RoleSlave); again != d {
+		t.Error("datasource should be cached")
+	}
+
+	// 需要在从库间负载均衡时使用Router
+	router, err := NewRouter(d.Config(), WithHealthCheck(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer router.Close()
+	names := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		names[router.nameOf(router.Read(context.Background()))] = true
+	}
+	if len(names) != 2 || !names["s1"] || !names["s2"] {
+		t.Errorf("router should read from both slaves, got %v", names)
+	}
+}
@@ -0,0 +1,295 @@
+package gconf_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 从库的负载均衡策略
+type Balancer string
+
+const (
+	RoundRobin Balancer = "round-robin"
+	Weighted   Balancer = "weighted"    // 按MysqlServer.Weight平滑加权轮询
+	LeastConns Balancer = "least-conns" // 选择使用中连接最少的从库
+)
+
+const (
+	defaultHealthInterval = 5 * time.Second
+	defaultHealthTimeout  = time.Second
+	defaultMaxFailures    = 3
+)
+
+type routerOptions struct {
+	balancer       Balancer
+	healthInterval time.Duration
+	healthTimeout  time.Duration
+	maxFailures    int
+	logger         *slog.Logger
+}
+
+type RouterOption func(*routerOptions)
+
+// 指定从库的负载均衡策略，默认为RoundRobin
+func WithBalancer(b Balancer) RouterOption {
+	return func(o *routerOptions) {
+		o.balancer = b
+	}
+}
+
+// 从库健康检查的间隔及单次超时，默认5s、1s。interval<=0时不检查
+func WithHealthCheck(interval, timeout time.Duration) RouterOption {
+	return func(o *routerOptions) {
+		o.healthInterval = interval
+		o.healthTimeout = timeout
+	}
+}
+
+// 从库连续检查失败多少次后摘除，默认3次。摘除后检查成功一次即恢复
+func WithMaxFailures(n int) RouterOption {
+	return func(o *routerOptions) {
+		o.maxFailures = n
+	}
+}
+
+// 指定路由器的日志输出，默认使用slog.Default()
+func WithRouterLogger(logger *slog.Logger) RouterOption {
+	return func(o *routerOptions) {
+		o.logger = logger
+	}
+}
+
+type forceMasterKey struct{}
+
+// 返回强制读主库的ctx，用于写后立即读等需要读到最新数据的场景
+func WithMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceMasterKey{}, true)
+}
+
+// ctx是否要求读主库
+func IsMaster(ctx context.Context) bool {
+	v, _ := ctx.Value(forceMasterKey{}).(bool)
+	return v
+}
+
+type replica struct {
+	server   *MysqlServer
+	db       *sql.DB
+	weight   int
+	current  int // 平滑加权轮询的当前权重，由Router.mux保护
+	healthy  atomic.Bool
+	failures int // 连续检查失败次数，仅健康检查协程访问
+}
+
+// 从库状态，见Router.Replicas
+type ReplicaStatus struct {
+	Name    string
+	Addr    string
+	Weight  int
+	Healthy bool
+	InUse   int
+}
+
+// 读写分离路由器，写入及强制读主库的查询使用主库，其余查询在健康的从库间负载均衡，
+// 所有从库都不可用时使用主库
+//
+//	r, err := gconf_mysql.NewRouter(gconf_mysql.GetDefaultMySQLDataSourceConfig())
+//	defer r.Close()
+//	r.Master().ExecContext(ctx, "update ...")
+//	r.Read(gconf_mysql.WithMaster(ctx)).QueryContext(ctx, "select ...")
+type Router struct {
+	master   *sql.DB
+	replicas []*replica
+	balancer Balancer
+	logger   *slog.Logger
+
+	next atomic.Uint64
+	mux  sync.Mutex
+
+	ping   func(ctx context.Context, db *sql.DB) error
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// 按配置中的所有MysqlServers建立主库及从库的连接池，只有一个服务器时将其作为主库
+func NewRouter(config *MySQLDataSourceConfig, opts ...RouterOption) (*Router, error) {
+	o := &routerOptions{
+		balancer:       RoundRobin,
+		healthInterval: defaultHealthInterval,
+		healthTimeout:  defaultHealthTimeout,
+		maxFailures:    defaultMaxFailures,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	switch o.balancer {
+	case RoundRobin, Weighted, LeastConns:
+	default:
+		return nil, fmt.Errorf("gconf_mysql: unknown balancer %q", o.balancer)
+	}
+
+	master, slaves, err := config.Servers()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Router{
+		balancer: o.balancer,
+		logger:   o.logger.With("component", "gconf_mysql", "dbName", config.DbName),
+		ping: func(ctx context.Context, db *sql.DB) error {
+			return db.PingContext(ctx)
+		},
+	}
+	if r.master, err = config.OpenServer(master); err != nil {
+		return nil, err
+	}
+	for _, ms := range slaves {
+		db, err := config.OpenServer(ms)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		rep := &replica{server: ms, db: db, weight: ms.Weight}
+		if rep.weight <= 0 {
+			rep.weight = 1
+		}
+		rep.healthy.Store(true)
+		r.replicas = append(r.replicas, rep)
+	}
+	if o.healthInterval > 0 && len(r.replicas) > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		r.cancel, r.done = cancel, make(chan struct{})
+		go r.healthCheck(ctx, o.healthInterval, o.healthTimeout, o.maxFailures)
+	}
+	return r, nil
+}
+
+// 主库连接池
+func (r *Router) Master() *sql.DB {
+	return r.master
+}
+
+// 读查询使用的连接池。ctx经WithMaster标记或没有健康的从库时返回主库
+func (r *Router) Read(ctx context.Context) *sql.DB {
+	if IsMaster(ctx) {
+		return r.master
+	}
+	if rep := r.pick(); rep != nil {
+		return rep.db
+	}
+	return r.master
+}
+
+func (r *Router) pick() *replica {
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy = append(healthy, rep)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	switch r.balancer {
+	case Weighted:
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		var best *replica
+		total := 0
+		for _, rep := range healthy {
+			rep.current += rep.weight
+			total += rep.weight
+			if best == nil || rep.current > best.current {
+				best = rep
+			}
+		}
+		best.current -= total
+		return best
+	case LeastConns:
+		best := healthy[0]
+		for _, rep := range healthy[1:] {
+			if rep.db.Stats().InUse < best.db.Stats().InUse {
+				best = rep
+			}
+		}
+		return best
+	default:
+		return healthy[(r.next.Add(1)-1)%uint64(len(healthy))]
+	}
+}
+
+// 各从库的状态，按配置顺序排列
+func (r *Router) Replicas() []ReplicaStatus {
+	res := make([]ReplicaStatus, len(r.replicas))
+	for i, rep := range r.replicas {
+		res[i] = ReplicaStatus{
+			Name:    rep.server.Name,
+			Addr:    rep.server.addr(),
+			Weight:  rep.weight,
+			Healthy: rep.healthy.Load(),
+			InUse:   rep.db.Stats().InUse,
+		}
+	}
+	return res
+}
+
+// 停止健康检查并关闭所有连接池
+func (r *Router) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	var errs []error
+	if r.master != nil {
+		errs = append(errs, r.master.Close())
+	}
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Router) healthCheck(ctx context.Context, interval, timeout time.Duration, maxFailures int) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, rep := range r.replicas {
+			pingCtx, cancel := context.WithTimeout(ctx, timeout)
+			err := r.ping(pingCtx, rep.db)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			r.checked(rep, err, maxFailures)
+		}
+	}
+}
+
+func (r *Router) checked(rep *replica, err error, maxFailures int) {
+	if err == nil {
+		rep.failures = 0
+		if !rep.healthy.Swap(true) {
+			r.logger.Info("replica recovered", "name", rep.server.Name, "addr", rep.server.addr())
+		}
+		return
+	}
+	rep.failures++
+	if rep.failures >= maxFailures && rep.healthy.Swap(false) {
+		r.logger.Warn("replica ejected", "name", rep.server.Name, "addr", rep.server.addr(), "error", err)
+	}
+}
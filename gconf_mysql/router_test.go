@@ -0,0 +1,120 @@
+package gconf_mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func testRouterConfig() *MySQLDataSourceConfig {
+	return &MySQLDataSourceConfig{
+		DbName:   "ucenter",
+		Username: "root",
+		Password: "123456",
+		MysqlServers: []*MysqlServer{
+			{Name: "s1", Ip: "127.0.0.1", Port: "3307", Role: "slave", Weight: 1},
+			{Name: "m", Ip: "127.0.0.1", Port: "3306", Role: "master"},
+			{Name: "s2", Ip: "127.0.0.1", Port: "3308", Role: "slave", Weight: 3},
+		},
+	}
+}
+
+func newTestRouter(t *testing.T, opts ...RouterOption) *Router {
+	r, err := NewRouter(testRouterConfig(), append([]RouterOption{WithHealthCheck(0, 0)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func (r *Router) nameOf(db *sql.DB) string {
+	if db == r.master {
+		return "m"
+	}
+	for _, rep := range r.replicas {
+		if rep.db == db {
+			return rep.server.Name
+		}
+	}
+	return ""
+}
+
+func TestRouterBalance(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRouter(t)
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, r.nameOf(r.Read(ctx)))
+	}
+	if want := []string{"s1", "s2", "s1", "s2"}; !slices.Equal(got, want) {
+		t.Errorf("round-robin = %v, want %v", got, want)
+	}
+	if name := r.nameOf(r.Read(WithMaster(ctx))); name != "m" {
+		t.Errorf("forced master read went to %s", name)
+	}
+
+	r = newTestRouter(t, WithBalancer(Weighted))
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[r.nameOf(r.Read(ctx))]++
+	}
+	if counts["s1"] != 2 || counts["s2"] != 6 {
+		t.Errorf("weighted counts = %v", counts)
+	}
+
+	r = newTestRouter(t, WithBalancer(LeastConns))
+	if name := r.nameOf(r.Read(ctx)); name != "s1" {
+		t.Errorf("least-conns picked %s", name)
+	}
+
+	if _, err := NewRouter(testRouterConfig(), WithBalancer("random")); err == nil {
+		t.Error("unknown balancer should fail")
+	}
+}
+
+func TestRouterHealth(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRouter(t)
+	s1 := r.replicas[0]
+	failure := errors.New("down")
+	r.checked(s1, failure, 2)
+	if !r.Replicas()[0].Healthy {
+		t.Fatal("replica ejected before max failures")
+	}
+	r.checked(s1, failure, 2)
+	if r.Replicas()[0].Healthy {
+		t.Fatal("replica should be ejected")
+	}
+	for i := 0; i < 3; i++ {
+		if name := r.nameOf(r.Read(ctx)); name != "s2" {
+			t.Errorf("read went to %s", name)
+		}
+	}
+	r.checked(r.replicas[1], failure, 1)
+	if name := r.nameOf(r.Read(ctx)); name != "m" {
+		t.Errorf("read without healthy replicas went to %s", name)
+	}
+	r.checked(s1, nil, 2)
+	if name := r.nameOf(r.Read(ctx)); name != "s1" {
+		t.Errorf("recovered replica not used, got %s", name)
+	}
+}
+
+func TestRouterSingleServer(t *testing.T) {
+	config := &MySQLDataSourceConfig{DbName: "ucenter", Username: "root",
+		MysqlServers: []*MysqlServer{{Name: "only", Ip: "127.0.0.1", Port: "3306"}}}
+	r, err := NewRouter(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Read(context.Background()) != r.Master() || len(r.Replicas()) != 0 {
+		t.Error("single server should serve reads from master")
+	}
+	if _, err := NewRouter(&MySQLDataSourceConfig{}); err == nil {
+		t.Error("config without servers should fail")
+	}
+}
@@ -0,0 +1,27 @@
+package gconf
+
+import "time"
+
+// 客户端运行指标的观察者，通过WithObserver注册。
+// 方法会在请求及后台同步goroutine里同步调用，实现需并发安全且不要阻塞。
+type Observer interface {
+	// 每次HTTP请求结束时调用，连接失败时statusCode为0
+	ObserveRequest(endpoint, path string, statusCode int, d time.Duration, err error)
+	// 每轮watch结束时调用，changed为服务端返回的需要更新的配置集合数
+	ObserveWatch(appIds []string, changed int, err error)
+	// 每次拉取配置集合后调用，keysChanged为发生变化的key数
+	ObserveRefresh(appId string, keysChanged int, err error)
+	// 每个监听器执行后调用，panicked表示监听器发生了panic
+	ObserveListener(appId, key string, d time.Duration, panicked bool)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveRequest(endpoint, path string, statusCode int, d time.Duration, err error) {
+}
+
+func (nopObserver) ObserveWatch(appIds []string, changed int, err error) {}
+
+func (nopObserver) ObserveRefresh(appId string, keysChanged int, err error) {}
+
+func (nopObserver) ObserveListener(appId, key string, d time.Duration, panicked bool) {}
@@ -0,0 +1,192 @@
+package gconf
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const defaultProbeInterval = time.Second * 10
+
+type options struct {
+	endpoints     []string
+	probeInterval time.Duration
+	observer      Observer
+	logger        *slog.Logger
+	redactor      *Redactor
+	tracer        trace.TracerProvider
+	propagator    propagation.TextMapPropagator
+	memory        *MemoryProvider
+	region        string
+	interpolation bool
+	profiles      bool
+	labels        []string
+}
+
+// 客户端选项，用于Init及NewClient
+type Option func(*options)
+
+// 指定gconf服务地址列表，如 http://10.0.0.1:8080/api，按顺序优先使用。
+// 未指定时读取环境变量GCONF_ENDPOINTS(逗号分隔)，再未指定时按运行环境推断。
+func WithEndpoints(endpoints ...string) Option {
+	return func(o *options) {
+		o.endpoints = append(o.endpoints, endpoints...)
+	}
+}
+
+// 按指定区域(如prod-sh、test-ali)推断gconf服务地址，忽略WORK_REGION及k8s环境，用于访问其他区域的配置。
+// 指定了WithEndpoints时不生效
+func WithRegion(region string) Option {
+	return func(o *options) {
+		o.region = region
+	}
+}
+
+// 指定不可用地址的探测间隔，默认10秒，不大于0时使用默认值
+func WithProbeInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.probeInterval = d
+		}
+	}
+}
+
+// 注册运行指标的观察者，见gconf_metrics
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		o.observer = observer
+	}
+}
+
+// 指定日志输出，默认使用slog.Default()。
+// 配置变化在Info级别输出变化概要，Debug级别输出脱敏后的完整值
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 指定日志脱敏规则，默认为DefaultRedactKeys及DefaultRedactValues，为nil时使用默认规则
+func WithRedactor(redactor *Redactor) Option {
+	return func(o *options) {
+		if redactor != nil {
+			o.redactor = redactor
+		}
+	}
+}
+
+// 指定OpenTelemetry的TracerProvider，为请求、配置集合加载及刷新创建span，默认不创建
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.tracer = tp
+	}
+}
+
+// 指定在请求头中传播trace上下文的方式，默认为W3C Trace Context
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(o *options) {
+		o.propagator = propagator
+	}
+}
+
+// 使用内存配置源代替gconf服务端，用于测试或离线运行，见gconftest
+func WithMemoryProvider(p *MemoryProvider) Option {
+	return func(o *options) {
+		o.memory = p
+	}
+}
+
+// 解析值中的占位符：${key}引用同一集合的配置，${app:key}引用其他集合的配置，${env:NAME}引用环境变量，
+// 均可写作${...:-default}指定默认值，$${输出${。
+// 被引用的配置变化时，引用方的监听器及Register的bean同样会更新。
+// 引用的集合在首次解析时获取，获取失败后按指数退避(最长1分钟)重试
+func WithInterpolation() Option {
+	return func(o *options) {
+		o.interpolation = true
+	}
+}
+
+// 启用按环境覆盖配置：集合中的timeout@prod-sh.properties、timeout@canary.properties等覆盖timeout.properties，
+// GetValue获取基础key时返回生效的值。生效的profile按优先级从高到低依次为labels及区域(WithRegion或WORK_REGION)，
+// labels为空时读取环境变量GCONF_LABELS(逗号分隔)。集合中的定向规则(见TargetingKey)命中的profile优先级最高。
+// 通过ConfigCollection.Explain查看生效的覆盖配置
+func WithProfiles(labels ...string) Option {
+	return func(o *options) {
+		o.profiles = true
+		o.labels = append(o.labels, labels...)
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		probeInterval: defaultProbeInterval,
+		observer:      nopObserver{},
+		redactor:      defaultRedactor,
+		tracer:        noop.NewTracerProvider(),
+		propagator:    propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	if len(o.endpoints) == 0 {
+		for _, e := range strings.Split(os.Getenv("GCONF_ENDPOINTS"), ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				o.endpoints = append(o.endpoints, e)
+			}
+		}
+	}
+	if len(o.endpoints) == 0 && o.region != "" {
+		o.endpoints = []string{regionEndpoint(o.region)}
+	}
+	if len(o.endpoints) == 0 {
+		o.endpoints = []string{defaultEndpoint()}
+	}
+	return o
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func inK8s() bool {
+	return len(os.Getenv("KUBERNETES_SERVICE_HOST")) > 0
+}
+
+// 根据运行环境推断gconf服务地址
+func defaultEndpoint() string {
+	if inK8s() {
+		return "http://gconf/api"
+	}
+	return regionEndpoint(getEnv("WORK_REGION", "dev-ofc"))
+}
+
+// 区域对应的gconf服务地址
+func regionEndpoint(workRegion string) string {
+	domainSuffix := "dev.ofc"
+	if workRegion == "dev-ofc" {
+		domainSuffix = "dev.ofc"
+	} else if workRegion == "test-ali" {
+		domainSuffix = "test.ali"
+	} else if workRegion == "stage-sh" {
+		domainSuffix = "product.sh"
+	} else if workRegion == "prod-sh" {
+		domainSuffix = "product.sh"
+	} else if workRegion == "stage-lyra" {
+		domainSuffix = "product.lyra"
+	} else if workRegion == "prod-lyra" {
+		domainSuffix = "product.lyra"
+	}
+	return "http://gconf.services." + domainSuffix + "/api"
+}
@@ -0,0 +1,185 @@
+package gconf
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// 覆盖配置的key，timeout.properties在profile为prod-sh时为timeout@prod-sh.properties
+func overlayKey(key, profile string) string {
+	for _, ext := range []string{".properties", ".json"} {
+		if strings.HasSuffix(key, ext) {
+			return strings.TrimSuffix(key, ext) + "@" + profile + ext
+		}
+	}
+	return key + "@" + profile
+}
+
+// 拆分覆盖配置的key，返回基础key及profile，不是覆盖配置时ok为false
+func splitOverlay(key string) (base, profile string, ok bool) {
+	i := strings.LastIndexByte(key, '@')
+	if i < 0 {
+		return key, "", false
+	}
+	profile, ext := key[i+1:], ""
+	for _, e := range []string{".properties", ".json"} {
+		if strings.HasSuffix(profile, e) {
+			profile, ext = strings.TrimSuffix(profile, e), e
+			break
+		}
+	}
+	if profile == "" || key[:i] == "" {
+		return key, "", false
+	}
+	return key[:i] + ext, profile, true
+}
+
+func isOverlay(key string) bool {
+	_, _, ok := splitOverlay(key)
+	return ok
+}
+
+// 实例的labels及区域，labels为空时读取环境变量GCONF_LABELS，区域为空时读取WORK_REGION
+func instanceLabels(labels []string, region string) ([]string, string) {
+	if len(labels) == 0 {
+		for _, l := range strings.Split(os.Getenv("GCONF_LABELS"), ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				labels = append(labels, l)
+			}
+		}
+	}
+	if region == "" {
+		region = os.Getenv("WORK_REGION")
+	}
+	return labels, region
+}
+
+// 生效的profile，labels优先于区域
+func activeProfiles(labels []string, region string) []string {
+	profiles := append([]string{}, labels...)
+	if region != "" {
+		profiles = append(profiles, region)
+	}
+	return profiles
+}
+
+// 返回客户端生效的profile，按优先级从高到低，不含定向规则命中的profile。未启用WithProfiles时返回nil
+func (c *Client) Profiles() []string {
+	return append([]string(nil), c.profiles...)
+}
+
+// 基础key当前生效的配置key，按profile优先级查找未删除的覆盖配置，都没有时使用基础配置。
+// 调用方需持有dataMux
+func (c *ConfigCollection) winnerLocked(key string) (string, *Value) {
+	for _, p := range c.profilesLocked() {
+		k := overlayKey(key, p)
+		if v, ok := c.data[k]; ok && !v.isRemoved() {
+			return k, v
+		}
+	}
+	if v, ok := c.data[key]; ok && !v.isRemoved() {
+		return key, v
+	}
+	return "", nil
+}
+
+// 按当前配置更新基础key生效的值，值变化时触发基础key的监听器
+func (c *ConfigCollection) refreshEffective() {
+	c.dataMux.RLock()
+	bases := map[string]bool{}
+	for k := range c.data {
+		base, _, _ := splitOverlay(k)
+		bases[base] = true
+	}
+	type winner struct {
+		key   string
+		value string
+	}
+	winners := make(map[string]winner, len(bases))
+	for base := range bases {
+		k, v := c.winnerLocked(base)
+		if v != nil {
+			winners[base] = winner{key: k, value: v.Template()}
+		} else {
+			winners[base] = winner{}
+		}
+	}
+	c.dataMux.RUnlock()
+
+	for base, w := range winners {
+		c.dataMux.RLock()
+		e, ok := c.effective[base]
+		c.dataMux.RUnlock()
+		if !ok {
+			if w.key == "" {
+				continue
+			}
+			e = newValue(c, base, w.value)
+			e.origin = w.key
+			c.dataMux.Lock()
+			c.effective[base] = e
+			c.dataMux.Unlock()
+			if c.isLoaded() {
+				c.fireValueChanged(base, "", c.resolved(e))
+			}
+			continue
+		}
+
+		o := e.Raw()
+		wasRemoved := e.isRemoved()
+		if w.key == "" {
+			if !wasRemoved {
+				e.setRemoved(true)
+				c.fireValueChanged(base, o, "")
+			}
+			continue
+		}
+		e.setOrigin(w.key)
+		if wasRemoved {
+			e.setRemoved(false)
+			e.refresh(w.value)
+			c.fireValueChanged(base, "", c.resolved(e))
+		} else if e.refresh(w.value) {
+			c.fireValueChanged(base, o, c.resolved(e))
+		}
+	}
+}
+
+// 覆盖配置，见Explain
+type Overlay struct {
+	Key     string
+	Profile string
+	Active  bool // profile是否生效
+}
+
+// 基础key的覆盖解析结果
+type Resolution struct {
+	Key      string    // 基础key
+	Profiles []string  // 生效的profile，按优先级从高到低，包括定向规则命中的profile
+	Winner   string    // 生效的配置key，没有可用配置时为空
+	Overlays []Overlay // 集合中该key的所有覆盖配置，按key排序
+}
+
+// 返回基础key的覆盖解析结果，用于排查生效的是哪个覆盖配置
+func (c *ConfigCollection) Explain(key string) Resolution {
+	res := Resolution{Key: key, Profiles: c.Profiles()}
+	active := map[string]bool{}
+	for _, p := range res.Profiles {
+		active[p] = true
+	}
+	c.dataMux.RLock()
+	defer c.dataMux.RUnlock()
+	if c.client.profiles != nil {
+		res.Winner, _ = c.winnerLocked(key)
+	} else if v, ok := c.data[key]; ok && !v.isRemoved() {
+		res.Winner = key
+	}
+	for k, v := range c.data {
+		if base, p, ok := splitOverlay(k); ok && base == key && !v.isRemoved() {
+			res.Overlays = append(res.Overlays, Overlay{Key: k, Profile: p, Active: active[p]})
+		}
+	}
+	sort.Slice(res.Overlays, func(i, j int) bool { return res.Overlays[i].Key < res.Overlays[j].Key })
+	return res
+}
@@ -0,0 +1,129 @@
+package gconf
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitOverlay(t *testing.T) {
+	for _, tc := range []struct {
+		key, base, profile string
+		ok                 bool
+	}{
+		{"timeout@prod-sh.properties", "timeout.properties", "prod-sh", true},
+		{"redis@canary.json", "redis.json", "canary", true},
+		{"db.host@canary", "db.host", "canary", true},
+		{"timeout.properties", "timeout.properties", "", false},
+		{"mail@", "mail@", "", false},
+		{"@canary", "@canary", "", false},
+	} {
+		base, profile, ok := splitOverlay(tc.key)
+		if base != tc.base || profile != tc.profile || ok != tc.ok {
+			t.Errorf("splitOverlay(%q) = %q, %q, %v", tc.key, base, profile, ok)
+		}
+		if tc.ok && overlayKey(base, profile) != tc.key {
+			t.Errorf("overlayKey(%q, %q) = %q", base, profile, overlayKey(base, profile))
+		}
+	}
+}
+
+func TestActiveProfiles(t *testing.T) {
+	t.Setenv("GCONF_LABELS", "canary, blue")
+	t.Setenv("WORK_REGION", "prod-sh")
+	if got := activeProfiles(instanceLabels(nil, "")); !reflect.DeepEqual(got, []string{"canary", "blue", "prod-sh"}) {
+		t.Errorf("unexpected profiles %v", got)
+	}
+	if got := activeProfiles(instanceLabels([]string{"green"}, "test-ali")); !reflect.DeepEqual(got, []string{"green", "test-ali"}) {
+		t.Errorf("unexpected profiles %v", got)
+	}
+}
+
+type timeoutConfig struct {
+	Timeout int `config:"timeout"`
+}
+
+func TestProfiles(t *testing.T) {
+	p := NewMemoryProvider()
+	p.SetCollection("app", map[string]string{
+		"timeout.properties":          "timeout=1",
+		"timeout@prod-sh.properties":  "timeout=2",
+		"timeout@test-ali.properties": "timeout=3",
+		"banner@canary":               "canary only",
+	})
+	c := NewClient("app", WithMemoryProvider(p), WithRegion("prod-sh"), WithProfiles("canary"))
+	defer c.Close()
+	collection := c.GetCurrentConfigCollection()
+
+	v := collection.GetValue("timeout.properties")
+	if v.Raw() != "timeout=2" || v.Origin() != "timeout@prod-sh.properties" {
+		t.Fatalf("unexpected effective value %q from %q", v.Raw(), v.Origin())
+	}
+	if got := collection.GetValue("banner").Raw(); got != "canary only" {
+		t.Errorf("overlay without base not resolved: %q", got)
+	}
+	if got := collection.GetValue("timeout@test-ali.properties").Raw(); got != "timeout=3" {
+		t.Errorf("overlay key not readable: %q", got)
+	}
+	want := Resolution{
+		Key:      "timeout.properties",
+		Profiles: []string{"canary", "prod-sh"},
+		Winner:   "timeout@prod-sh.properties",
+		Overlays: []Overlay{
+			{Key: "timeout@prod-sh.properties", Profile: "prod-sh", Active: true},
+			{Key: "timeout@test-ali.properties", Profile: "test-ali"},
+		},
+	}
+	if got := collection.Explain("timeout.properties"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Explain = %+v, want %+v", got, want)
+	}
+
+	cfg := new(timeoutConfig)
+	if err := v.Register(cfg); err != nil || cfg.Timeout != 2 {
+		t.Fatalf("register: %v %v", cfg.Timeout, err)
+	}
+	var changes []string
+	collection.AddConfigChangeListener("timeout.properties", ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		changes = append(changes, oldValue+" -> "+newValue)
+	}))
+	refresh := func() {
+		t.Helper()
+		if err := c.Refresh(context.Background(), "app"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p.Set("app", "timeout@canary.properties", "timeout=4")
+	refresh()
+	if cfg.Timeout != 4 || v.Origin() != "timeout@canary.properties" || collection.GetValue("timeout.properties") != v {
+		t.Errorf("canary overlay not applied: %d %q", cfg.Timeout, v.Origin())
+	}
+	p.Set("app", "timeout.properties", "timeout=5") //被覆盖的基础配置变化不影响生效的值
+	refresh()
+	p.Delete("app", "timeout@canary.properties")
+	p.Delete("app", "timeout@prod-sh.properties")
+	refresh()
+	if cfg.Timeout != 5 || v.Origin() != "timeout.properties" {
+		t.Errorf("base not restored: %d %q", cfg.Timeout, v.Origin())
+	}
+	p.Delete("app", "timeout.properties")
+	refresh()
+	wantChanges := []string{"timeout=2 -> timeout=4", "timeout=4 -> timeout=5", "timeout=5 -> "}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("changes = %q, want %q", changes, wantChanges)
+	}
+}
+
+func TestProfilesDisabled(t *testing.T) {
+	p := NewMemoryProvider()
+	p.SetCollection("app", map[string]string{"a": "base", "a@prod-sh": "overlay"})
+	c := NewClient("app", WithMemoryProvider(p), WithRegion("prod-sh"))
+	defer c.Close()
+	collection := c.GetCurrentConfigCollection()
+	if got := collection.GetValue("a").Raw(); got != "base" {
+		t.Errorf("overlay applied without WithProfiles: %q", got)
+	}
+	if got := collection.Explain("a"); got.Winner != "a" || len(got.Overlays) != 1 || got.Overlays[0].Active {
+		t.Errorf("unexpected resolution %+v", got)
+	}
+}
@@ -0,0 +1,133 @@
+package gconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const readyRetryInterval = time.Second
+
+// 配置集合的加载状态
+type CollectionState struct {
+	AppId        string    `json:"appId"`
+	Loaded       bool      `json:"loaded"`
+	LastSync     time.Time `json:"lastSync"`     // 最近一次确认与服务端一致的时间
+	StaleSeconds float64   `json:"staleSeconds"` // 距LastSync的秒数，未加载时为0
+}
+
+// 客户端就绪状态
+type ReadyStatus struct {
+	Ready       bool              `json:"ready"`
+	Collections []CollectionState `json:"collections"`
+}
+
+// 阻塞直到所有appId的配置集合首次加载成功，或ctx结束。appIds为空时等待当前应用。
+// 等待过的appId会纳入Ready的检查范围。
+func (c *Client) WaitReady(ctx context.Context, appIds ...string) error {
+	if len(appIds) == 0 {
+		appIds = []string{c.appId}
+	}
+	c.mux.Lock()
+	for _, appId := range appIds {
+		c.readyAppIds[appId] = true
+	}
+	c.mux.Unlock()
+
+	for _, appId := range appIds {
+		if err := c.waitLoaded(ctx, appId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) waitLoaded(ctx context.Context, appId string) error {
+	for {
+		collection, err := c.loadConfigCollection(ctx, appId)
+		if collection != nil {
+			if collection.isLoaded() {
+				return nil
+			}
+			if err = collection.refreshData(ctx); err == nil {
+				return nil
+			}
+		}
+		t := time.NewTimer(readyRetryInterval)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("gconf: wait for %s: %w, last error: %v", appId, ctx.Err(), err)
+		}
+	}
+}
+
+// 所有已获取及WaitReady等待过的配置集合均已加载时返回true
+func (c *Client) Ready() bool {
+	return c.ReadyStatus().Ready
+}
+
+// 返回各配置集合的加载状态
+func (c *Client) ReadyStatus() ReadyStatus {
+	c.mux.RLock()
+	appIds := make(map[string]bool, len(c.readyAppIds)+len(c.dataCache))
+	for appId := range c.readyAppIds {
+		appIds[appId] = true
+	}
+	for appId := range c.dataCache {
+		appIds[appId] = true
+	}
+	dataCache := make(map[string]*ConfigCollection, len(c.dataCache))
+	for k, v := range c.dataCache {
+		dataCache[k] = v
+	}
+	c.mux.RUnlock()
+
+	status := ReadyStatus{Ready: len(appIds) > 0, Collections: []CollectionState{}}
+	now := time.Now()
+	for appId := range appIds {
+		state := CollectionState{AppId: appId}
+		if collection, ok := dataCache[appId]; ok && collection.isLoaded() {
+			state.Loaded = true
+			state.LastSync = collection.health.snapshot().LastSync
+			state.StaleSeconds = now.Sub(state.LastSync).Seconds()
+		}
+		status.Ready = status.Ready && state.Loaded
+		status.Collections = append(status.Collections, state)
+	}
+	sort.Slice(status.Collections, func(i, j int) bool {
+		return status.Collections[i].AppId < status.Collections[j].AppId
+	})
+	return status
+}
+
+// 就绪探针，就绪时返回200，否则返回503，响应体为ReadyStatus的json
+func (c *Client) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := c.ReadyStatus()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+}
+
+// 使用默认客户端等待配置加载，见Client.WaitReady
+func WaitReady(ctx context.Context, appIds ...string) error {
+	return std.WaitReady(ctx, appIds...)
+}
+
+// 默认客户端是否就绪，见Client.Ready
+func Ready() bool {
+	return std.Ready()
+}
+
+// 默认客户端的就绪探针，见Client.ReadyHandler
+func ReadyHandler() http.Handler {
+	return std.ReadyHandler()
+}
@@ -0,0 +1,134 @@
+package gconf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	var listCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/getConfigApp":
+			w.Write([]byte(`{"configCollectionId":"app","name":"app"}`))
+		case "/listConfigs":
+			if atomic.AddInt32(&listCalls, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"a":"1"}`))
+		case "/watch":
+			time.Sleep(time.Millisecond * 50)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient("app", WithEndpoints(server.URL))
+	defer c.Close()
+	if c.Ready() {
+		t.Fatal("should not be ready before any collection is requested")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Ready() {
+		t.Fatal("should be ready")
+	}
+	if v := c.GetCurrentConfigCollection().GetValue("a").Raw(); v != "1" {
+		t.Fatalf("unexpected value %q", v)
+	}
+
+	rec := httptest.NewRecorder()
+	c.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected code %d", rec.Code)
+	}
+	status := ReadyStatus{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Collections) != 1 || !status.Collections[0].Loaded || status.Collections[0].AppId != "app" {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestNotReadyAfterFailedLoad(t *testing.T) {
+	var listUp, watches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/getConfigApp":
+			w.Write([]byte(`{"configCollectionId":"app","name":"app"}`))
+		case "/listConfigs":
+			if atomic.LoadInt32(&listUp) == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{"a":"1"}`))
+		case "/watch":
+			atomic.AddInt32(&watches, 1)
+			time.Sleep(time.Millisecond * 10)
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient("app", WithEndpoints(server.URL))
+	defer c.Close()
+	if c.GetCurrentConfigCollection() == nil {
+		t.Fatal("collection should be created")
+	}
+	// watch成功但未列出该集合时，首次加载失败的集合仍未就绪
+	deadline := time.Now().Add(time.Second * 5)
+	for atomic.LoadInt32(&watches) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("watch not called")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if c.Ready() {
+		t.Fatal("should not be ready after failed load")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if err := c.WaitReady(ctx); err == nil {
+		t.Fatal("expected timeout")
+	}
+
+	atomic.StoreInt32(&listUp, 1)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.GetCurrentConfigCollection().GetValue("a").Raw(); v != "1" {
+		t.Fatalf("unexpected value %q", v)
+	}
+}
+
+func TestWaitReadyTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewClient("app", WithEndpoints(server.URL))
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := c.WaitReady(ctx, "other"); err == nil {
+		t.Fatal("expected timeout")
+	}
+
+	rec := httptest.NewRecorder()
+	c.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected code %d", rec.Code)
+	}
+}
@@ -0,0 +1,205 @@
+package gconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// 默认脱敏的key模式，不区分大小写，语法同path.Match
+var DefaultRedactKeys = []string{"*password*", "*secret*"}
+
+// 默认脱敏的值特征，值中包含任一特征即脱敏
+var DefaultRedactValues = []string{"encryptedPassword"}
+
+var defaultRedactor = NewRedactor(DefaultRedactKeys, DefaultRedactValues)
+
+// 判断配置是否敏感，用于日志及导出时脱敏
+type Redactor struct {
+	keyPatterns  []string
+	valueMarkers []string
+}
+
+// keyPatterns匹配配置key(不区分大小写)，valueMarkers匹配值中包含的内容
+func NewRedactor(keyPatterns, valueMarkers []string) *Redactor {
+	r := &Redactor{}
+	for _, p := range keyPatterns {
+		r.keyPatterns = append(r.keyPatterns, strings.ToLower(p))
+	}
+	r.valueMarkers = append(r.valueMarkers, valueMarkers...)
+	return r
+}
+
+// key匹配任一模式，或值包含任一特征时返回true。r为nil时使用默认规则
+func (r *Redactor) Sensitive(key, value string) bool {
+	if r == nil {
+		r = defaultRedactor
+	}
+	lowKey := strings.ToLower(key)
+	for _, p := range r.keyPatterns {
+		if ok, _ := path.Match(p, lowKey); ok {
+			return true
+		}
+	}
+	for _, m := range r.valueMarkers {
+		if m != "" && strings.Contains(value, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// 敏感时返回[REDACTED]；properties及json中敏感的字段单独脱敏，其余原样返回。r为nil时使用默认规则
+func (r *Redactor) Redact(key, value string) string {
+	if value == "" {
+		return value
+	}
+	if r == nil {
+		r = defaultRedactor
+	}
+	if r.Sensitive(key, value) {
+		return redacted
+	}
+	switch fileTypeOf(key) {
+	case properties:
+		lines := strings.Split(value, "\n")
+		for i, line := range lines {
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) == 2 && r.Sensitive(strings.TrimSpace(kv[0]), kv[1]) {
+				lines[i] = kv[0] + "=" + redacted
+			}
+		}
+		return strings.Join(lines, "\n")
+	case jsons:
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return value
+		}
+		bs, err := json.Marshal(r.redactJson(v))
+		if err != nil {
+			return value
+		}
+		return string(bs)
+	}
+	return value
+}
+
+// key或值敏感，或properties及json中有敏感字段时返回true，即Redact会脱敏的配置。
+// 不依赖Redact的输出，json的格式及字段顺序不影响结果。r为nil时使用默认规则
+func (r *Redactor) ContainsSensitive(key, value string) bool {
+	if r == nil {
+		r = defaultRedactor
+	}
+	if r.Sensitive(key, value) {
+		return true
+	}
+	switch fileTypeOf(key) {
+	case properties:
+		for _, line := range strings.Split(value, "\n") {
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) == 2 && r.Sensitive(strings.TrimSpace(kv[0]), kv[1]) {
+				return true
+			}
+		}
+	case jsons:
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err == nil {
+			return r.jsonSensitive(v)
+		}
+	}
+	return false
+}
+
+func (r *Redactor) jsonSensitive(v any) bool {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, f := range x {
+			s, _ := f.(string)
+			if r.Sensitive(k, s) || r.jsonSensitive(f) {
+				return true
+			}
+		}
+	case []any:
+		for _, f := range x {
+			if r.jsonSensitive(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Redactor) redactJson(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, f := range x {
+			s, _ := f.(string)
+			if r.Sensitive(k, s) {
+				x[k] = redacted
+			} else {
+				x[k] = r.redactJson(f)
+			}
+		}
+	case []any:
+		for i, f := range x {
+			x[i] = r.redactJson(f)
+		}
+	}
+	return v
+}
+
+// 使用默认规则判断配置是否敏感
+func IsSensitive(key, value string) bool {
+	return defaultRedactor.Sensitive(key, value)
+}
+
+// 概括配置变化，properties及json列出增删改的字段名，文本只给出长度，不输出值
+func summarizeChange(key, oldValue, newValue string) string {
+	var oldFields, newFields map[string]string
+	switch fileTypeOf(key) {
+	case properties:
+		oldFields, newFields = ParseProperties(oldValue), ParseProperties(newValue)
+	case jsons:
+		oldFields, newFields = jsonFields(oldValue), jsonFields(newValue)
+	}
+	if oldFields == nil || newFields == nil {
+		return fmt.Sprintf("length %d -> %d", len(oldValue), len(newValue))
+	}
+	var added, removed, changed []string
+	for k, v := range newFields {
+		if o, ok := oldFields[k]; !ok {
+			added = append(added, k)
+		} else if o != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range oldFields {
+		if _, ok := newFields[k]; !ok {
+			removed = append(removed, k)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(changed)
+	return fmt.Sprintf("added %v, removed %v, changed %v", added, removed, changed)
+}
+
+// 返回json顶层字段的原始内容，解析失败返回nil
+func jsonFields(value string) map[string]string {
+	if value == "" {
+		return map[string]string{}
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(value), &m); err != nil {
+		return nil
+	}
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = string(v)
+	}
+	return res
+}
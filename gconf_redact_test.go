@@ -0,0 +1,66 @@
+package gconf
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r := defaultRedactor
+	if !r.Sensitive("db.Password", "x") || !r.Sensitive("app-secret", "x") {
+		t.Fatal("key patterns should match case-insensitively")
+	}
+	if !r.Sensitive("datasource.json", `{"encryptedPassword":"abc"}`) {
+		t.Fatal("value marker should match")
+	}
+	if r.Sensitive("timeout.properties", "a=1") {
+		t.Fatal("should not be sensitive")
+	}
+
+	if v := r.Redact("app.properties", "host=db\npassword=123"); v != "host=db\npassword="+redacted {
+		t.Fatalf("unexpected properties redaction %q", v)
+	}
+	if v := r.Redact("app.json", `{"host":"db","auth":{"secretKey":"x"}}`); v != `{"auth":{"secretKey":"`+redacted+`"},"host":"db"}` {
+		t.Fatalf("unexpected json redaction %q", v)
+	}
+
+	if !r.ContainsSensitive("app.properties", "host=db\npassword=123") || !r.ContainsSensitive("app.json", `{"list":[{"secretKey":"x"}]}`) {
+		t.Fatal("sensitive fields should be found")
+	}
+	if r.ContainsSensitive("app.json", "{\n  \"z\": 1,\n  \"a\": [\"x\"]\n}") || r.ContainsSensitive("app.properties", "a=1") {
+		t.Fatal("formatting should not make a value sensitive")
+	}
+
+	var nilRedactor *Redactor
+	if v := nilRedactor.Redact("db.password", "123"); v != redacted || newOptions([]Option{WithRedactor(nil)}).redactor != defaultRedactor {
+		t.Fatal("nil redactor should use the default rules")
+	}
+}
+
+func TestSummarizeChange(t *testing.T) {
+	if s := summarizeChange("a.properties", "a=1\nb=2", "a=1\nb=3\nc=4"); s != "added [c], removed [], changed [b]" {
+		t.Fatalf("unexpected summary %q", s)
+	}
+	if s := summarizeChange("a.json", `{"a":1,"b":2}`, `{"a":1}`); s != "added [], removed [b], changed []" {
+		t.Fatalf("unexpected summary %q", s)
+	}
+	if s := summarizeChange("a", "abc", "abcd"); s != "length 3 -> 4" {
+		t.Fatalf("unexpected summary %q", s)
+	}
+}
+
+func TestFireValueChangedRedacts(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := &Client{
+		logger:   slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		redactor: defaultRedactor,
+		observer: nopObserver{},
+	}
+	collection := newConfigCollection(c, "app", "app")
+	collection.fireValueChanged("datasource.json", `{"encryptedPassword":"s3cr3t-old"}`, `{"encryptedPassword":"s3cr3t-new"}`)
+	if out := buf.String(); strings.Contains(out, "s3cr3t") {
+		t.Fatalf("secret leaked into logs: %s", out)
+	}
+}
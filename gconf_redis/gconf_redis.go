@@ -1,9 +1,13 @@
 package gconf_redis
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/guanaitong/gconf-go-client"
@@ -16,74 +20,317 @@ type RedisType int
 const (
 	RedisStandalone RedisType = iota
 	RedisSentinel
+	RedisCluster
 )
 
 // 单机模式配置
 type RedisStandaloneConfig struct {
-	Host     string `json:"host"`
+	Host     string `json:"host"` // 为空时连接本机
 	Port     int    `json:"port"`
 	NodeHost string `json:"nodeHost"`
 	NodePort int    `json:"nodePort"`
 }
 
-// 哨兵模式配置
+// 哨兵模式配置。设置RouteByLatency或RouteRandomly时只读命令会路由到从库，
+// 需要使用NewUniversalClient，且不支持db
 type RedisSentinelConfig struct {
-	Master string `json:"master"`
-	Nodes  string `json:"nodes"`
+	Master            string `json:"master"`
+	Nodes             string `json:"nodes"`
+	Password          string `json:"password"` // 哨兵的密码，与redis的密码不同
+	EncryptedPassword string `json:"encryptedPassword"`
+	RouteByLatency    bool   `json:"routeByLatency"` // 只读命令发往延迟最低的节点
+	RouteRandomly     bool   `json:"routeRandomly"`  // 只读命令随机发往主库或从库
+	SlaveOnly         bool   `json:"slaveOnly"`      // 所有命令只发往从库
+}
+
+// 集群模式配置，只能使用NewUniversalClient，不支持db
+type RedisClusterConfig struct {
+	Nodes          string `json:"nodes"` // 逗号分隔的host:port，无需列出全部节点
+	MaxRedirects   int    `json:"maxRedirects"`
+	ReadOnly       bool   `json:"readOnly"`       // 只读命令发往从库
+	RouteByLatency bool   `json:"routeByLatency"` // 只读命令发往延迟最低的节点
+	RouteRandomly  bool   `json:"routeRandomly"`  // 只读命令随机发往主库或从库
+}
+
+// 时长，JSON中为"500ms"、"3s"格式的字符串
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"500ms\", got %s", b)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// TLS配置，证书及私钥为PEM格式，存放在与redis配置同一集合的其他key中
+type RedisTLSConfig struct {
+	ServerName         string `json:"serverName"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	CAKey              string `json:"caKey"`   // CA证书所在的key，为空时使用系统CA
+	CertKey            string `json:"certKey"` // 客户端证书所在的key，需与KeyKey同时设置
+	KeyKey             string `json:"keyKey"`  // 客户端私钥所在的key
 }
 
 type RedisConfig struct {
 	Type              RedisType             `json:"type"`
 	Standalone        RedisStandaloneConfig `json:"standalone"`
 	Sentinel          RedisSentinelConfig   `json:"sentinel"`
+	Cluster           RedisClusterConfig    `json:"cluster"`
 	Password          string                `json:"password"`
 	EncryptedPassword string                `json:"encryptedPassword"`
 	Db                int                   `json:"db"`
+
+	// 连接池及超时，未设置时使用go-redis的默认值
+	PoolSize     int             `json:"poolSize"`
+	MinIdleConns int             `json:"minIdleConns"`
+	MaxRetries   int             `json:"maxRetries"` // -1为不重试
+	DialTimeout  Duration        `json:"dialTimeout"`
+	ReadTimeout  Duration        `json:"readTimeout"` // -1ns为不超时
+	WriteTimeout Duration        `json:"writeTimeout"`
+	PoolTimeout  Duration        `json:"poolTimeout"`
+	IdleTimeout  Duration        `json:"idleTimeout"`
+	TLS          *RedisTLSConfig `json:"tls"` // 设置时使用TLS连接
+
+	collection *gconf.ConfigCollection // 配置所在的集合，用于读取TLS证书及错误信息
+	key        string
 }
 
-func (redisConfig *RedisConfig) NewClient() *redis.Client {
-	var pwd = gconf.Decrypt(redisConfig.EncryptedPassword)
-	if pwd == "" {
-		pwd = redisConfig.Password
-	}
-
-	if redisConfig.Type == RedisStandalone {
-		var (
-			host, port = redisConfig.Standalone.Host, redisConfig.Standalone.Port
-		)
-		opt := &redis.Options{
-			Addr:     fmt.Sprintf("%s:%d", host, port),
-			Password: pwd,
-			DB:       redisConfig.Db,
+// 字段field相关的错误
+func (redisConfig *RedisConfig) fieldError(field string, err error) error {
+	e := &gconf.ConfigError{Key: redisConfig.key, Field: field, Err: err}
+	if redisConfig.collection != nil {
+		e.AppId = redisConfig.collection.AppId()
+	}
+	return e
+}
+
+// 读取配置所在集合中的key，配置不是从集合读取时使用当前应用的集合
+func (redisConfig *RedisConfig) lookup(key string) (string, error) {
+	if redisConfig.collection != nil {
+		return redisConfig.collection.Lookup(key)
+	}
+	return gconf.Lookup(key)
+}
+
+func (redisConfig *RedisConfig) tlsConfig() (*tls.Config, error) {
+	t := redisConfig.TLS
+	if t == nil {
+		return nil, nil
+	}
+	res := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if t.CAKey != "" {
+		ca, err := redisConfig.lookup(t.CAKey)
+		if err != nil {
+			return nil, redisConfig.fieldError("tls.caKey", err)
 		}
-		return redis.NewClient(opt)
-	} else if redisConfig.Type == RedisSentinel {
-		fOpt := &redis.FailoverOptions{
-			MasterName:    redisConfig.Sentinel.Master,
-			SentinelAddrs: strings.Split(redisConfig.Sentinel.Nodes, ","),
-			Password:      pwd,
-			DB:            redisConfig.Db,
+		res.RootCAs = x509.NewCertPool()
+		if !res.RootCAs.AppendCertsFromPEM([]byte(ca)) {
+			return nil, redisConfig.fieldError("tls.caKey", fmt.Errorf("no certificate found in %s", t.CAKey))
 		}
-		return redis.NewFailoverClient(fOpt)
-	} else {
-		panic("unsupported type")
 	}
+	if (t.CertKey == "") != (t.KeyKey == "") {
+		return nil, redisConfig.fieldError("tls.certKey", errors.New("certKey and keyKey must be set together"))
+	}
+	if t.CertKey != "" {
+		cert, err := redisConfig.lookup(t.CertKey)
+		if err != nil {
+			return nil, redisConfig.fieldError("tls.certKey", err)
+		}
+		key, err := redisConfig.lookup(t.KeyKey)
+		if err != nil {
+			return nil, redisConfig.fieldError("tls.keyKey", err)
+		}
+		pair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+		if err != nil {
+			return nil, redisConfig.fieldError("tls.certKey", err)
+		}
+		res.Certificates = []tls.Certificate{pair}
+	}
+	return res, nil
 }
 
-func GetRedisConfig(key string) *RedisConfig {
-	if key == "" {
-		panic("redis config key is empty")
+func decryptPassword(encrypted, plain string) string {
+	if pwd := gconf.Decrypt(encrypted); pwd != "" {
+		return pwd
+	}
+	return plain
+}
+
+func splitNodes(nodes string) []string {
+	var res []string
+	for _, n := range strings.Split(nodes, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// 哨兵模式是否将只读命令路由到从库
+func (redisConfig *RedisConfig) sentinelRouting() bool {
+	return redisConfig.Sentinel.RouteByLatency || redisConfig.Sentinel.RouteRandomly
+}
+
+// 各模式通用的连接选项
+func (redisConfig *RedisConfig) universalOptions() (*redis.UniversalOptions, error) {
+	tlsConfig, err := redisConfig.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	opt := &redis.UniversalOptions{
+		Password:     decryptPassword(redisConfig.EncryptedPassword, redisConfig.Password),
+		DB:           redisConfig.Db,
+		PoolSize:     redisConfig.PoolSize,
+		MinIdleConns: redisConfig.MinIdleConns,
+		MaxRetries:   redisConfig.MaxRetries,
+		DialTimeout:  time.Duration(redisConfig.DialTimeout),
+		ReadTimeout:  time.Duration(redisConfig.ReadTimeout),
+		WriteTimeout: time.Duration(redisConfig.WriteTimeout),
+		PoolTimeout:  time.Duration(redisConfig.PoolTimeout),
+		IdleTimeout:  time.Duration(redisConfig.IdleTimeout),
+		TLSConfig:    tlsConfig,
+	}
+	switch redisConfig.Type {
+	case RedisStandalone:
+		opt.Addrs = []string{fmt.Sprintf("%s:%d", redisConfig.Standalone.Host, redisConfig.Standalone.Port)}
+	case RedisSentinel:
+		sentinel := redisConfig.Sentinel
+		if sentinel.Master == "" {
+			return nil, redisConfig.fieldError("sentinel.master", errors.New("master is empty"))
+		}
+		if opt.Addrs = splitNodes(sentinel.Nodes); len(opt.Addrs) == 0 {
+			return nil, redisConfig.fieldError("sentinel.nodes", errors.New("nodes is empty"))
+		}
+		if redisConfig.sentinelRouting() && redisConfig.Db != 0 {
+			return nil, redisConfig.fieldError("db", errors.New("db is not supported when routing to replicas"))
+		}
+		opt.MasterName = sentinel.Master
+		opt.SentinelPassword = decryptPassword(sentinel.EncryptedPassword, sentinel.Password)
+		opt.RouteByLatency, opt.RouteRandomly = sentinel.RouteByLatency, sentinel.RouteRandomly
+	case RedisCluster:
+		cluster := redisConfig.Cluster
+		if opt.Addrs = splitNodes(cluster.Nodes); len(opt.Addrs) == 0 {
+			return nil, redisConfig.fieldError("cluster.nodes", errors.New("nodes is empty"))
+		}
+		if redisConfig.Db != 0 {
+			return nil, redisConfig.fieldError("db", errors.New("db is not supported in cluster mode"))
+		}
+		opt.MaxRedirects = cluster.MaxRedirects
+		opt.ReadOnly, opt.RouteByLatency, opt.RouteRandomly = cluster.ReadOnly, cluster.RouteByLatency, cluster.RouteRandomly
+	default:
+		return nil, redisConfig.fieldError("type", fmt.Errorf("unsupported type %d", redisConfig.Type))
+	}
+	return opt, nil
+}
+
+func (redisConfig *RedisConfig) failoverOptions(opt *redis.UniversalOptions) *redis.FailoverOptions {
+	fOpt := opt.Failover()
+	fOpt.RouteByLatency, fOpt.RouteRandomly = opt.RouteByLatency, opt.RouteRandomly
+	fOpt.SlaveOnly = redisConfig.Sentinel.SlaveOnly
+	return fOpt
+}
+
+func (redisConfig *RedisConfig) NewClient() *redis.Client {
+	client, err := redisConfig.NewClientE()
+	if err != nil {
+		panic(err.Error())
+	}
+	return client
+}
+
+// 同NewClient，类型不支持或缺少地址时返回错误。集群模式及哨兵模式路由到从库时需使用NewUniversalClientE
+func (redisConfig *RedisConfig) NewClientE() (*redis.Client, error) {
+	opt, err := redisConfig.universalOptions()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case redisConfig.Type == RedisCluster:
+		return nil, redisConfig.fieldError("type", errors.New("cluster requires NewUniversalClient"))
+	case redisConfig.Type == RedisSentinel && redisConfig.sentinelRouting():
+		return nil, redisConfig.fieldError("sentinel", errors.New("routing to replicas requires NewUniversalClient"))
+	case redisConfig.Type == RedisSentinel:
+		return redis.NewFailoverClient(redisConfig.failoverOptions(opt)), nil
+	default:
+		return redis.NewClient(opt.Simple()), nil
+	}
+}
+
+// 返回适用于所有模式的客户端：单机及哨兵模式为*redis.Client，集群模式及哨兵模式路由到从库时为*redis.ClusterClient
+func (redisConfig *RedisConfig) NewUniversalClient() redis.UniversalClient {
+	client, err := redisConfig.NewUniversalClientE()
+	if err != nil {
+		panic(err.Error())
 	}
+	return client
+}
 
-	redisConfig := new(RedisConfig)
-	configValue := gconf.GetCurrentConfigCollection().GetValue(key).Raw()
-	err := json.Unmarshal([]byte(configValue), redisConfig)
+// 同NewUniversalClient，配置无效时返回错误
+func (redisConfig *RedisConfig) NewUniversalClientE() (redis.UniversalClient, error) {
+	opt, err := redisConfig.universalOptions()
+	if err != nil {
+		return nil, err
+	}
+	switch redisConfig.Type {
+	case RedisSentinel:
+		if redisConfig.sentinelRouting() {
+			return redis.NewFailoverClusterClient(redisConfig.failoverOptions(opt)), nil
+		}
+		return redis.NewFailoverClient(redisConfig.failoverOptions(opt)), nil
+	case RedisCluster:
+		return redis.NewClusterClient(opt.Cluster()), nil
+	default:
+		return redis.NewClient(opt.Simple()), nil
+	}
+}
+
+func GetRedisConfig(key string) *RedisConfig {
+	redisConfig, err := GetRedisConfigE(key)
 	if err != nil {
 		panic(err.Error())
 	}
 	return redisConfig
 }
 
+// 同GetRedisConfig，key为空、不存在或无法解析时返回错误
+func GetRedisConfigE(key string) (*RedisConfig, error) {
+	if key == "" {
+		return nil, errors.New("redis config key is empty")
+	}
+	configValue, err := gconf.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return parseRedisConfig(gconf.GetCurrentConfigCollection(), key, configValue)
+}
+
+func parseRedisConfig(c *gconf.ConfigCollection, key, value string) (*RedisConfig, error) {
+	redisConfig := &RedisConfig{collection: c, key: key}
+	if err := json.Unmarshal([]byte(value), redisConfig); err != nil {
+		return nil, &gconf.ConfigError{AppId: c.AppId(), Key: key, Err: err}
+	}
+	return redisConfig, nil
+}
+
 func GetDefaultRedisConfig() *RedisConfig {
 	return GetRedisConfig(defaultRedisConfigKey)
 }
+
+// 同GetDefaultRedisConfig，读取失败时返回错误
+func GetDefaultRedisConfigE() (*RedisConfig, error) {
+	return GetRedisConfigE(defaultRedisConfigKey)
+}
@@ -2,9 +2,23 @@ package gconf_redis
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
 )
 
 const (
@@ -13,8 +27,19 @@ const (
 	expected = "value"
 )
 
+// 设置GCONF_TEST_REDIS_CONFIG为redis-config.json的内容时，连接真实redis测试
+const testRedisConfig = `{"type":0,"standalone":{"host":"127.0.0.1","port":6379},"db":1}`
+
 func TestGetRedisConfig(t *testing.T) {
-	gconf.Init("for-test-java")
+	s := gconftest.NewServer()
+	defer s.Close()
+	redisConfig, integration := os.LookupEnv("GCONF_TEST_REDIS_CONFIG")
+	if !integration {
+		redisConfig = testRedisConfig
+	}
+	s.SetCollection("for-test-java", map[string]string{"redis-config.json": redisConfig})
+	gconf.Init("for-test-java", gconf.WithEndpoints(s.URL))
+
 	d := GetDefaultRedisConfig()
 	d2 := GetRedisConfig("redis-config.json")
 	if d == nil {
@@ -24,6 +49,12 @@ func TestGetRedisConfig(t *testing.T) {
 		t.Error("d2 is not nil")
 	}
 	client := d.NewClient()
+	if !integration {
+		if opt := client.Options(); opt.Addr != "127.0.0.1:6379" || opt.DB != 1 {
+			t.Errorf("unexpected options %s %d", opt.Addr, opt.DB)
+		}
+		return
+	}
 	ctx := context.Background()
 	err := client.Set(ctx, key, value, 0).Err()
 	if err != nil {
@@ -38,3 +69,175 @@ func TestGetRedisConfig(t *testing.T) {
 	}
 
 }
+
+func TestGetRedisConfigE(t *testing.T) {
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("redis-e", map[string]string{
+		"bad.json":      "{",
+		"type.json":     `{"type":9}`,
+		"sentinel.json": `{"type":1,"sentinel":{"nodes":"127.0.0.1:26379"}}`,
+	})
+	gconf.Init("redis-e", gconf.WithEndpoints(s.URL))
+
+	if _, err := GetRedisConfigE(""); err == nil {
+		t.Error("empty key should fail")
+	}
+	var ce *gconf.ConfigError
+	if _, err := GetRedisConfigE("missing.json"); !errors.Is(err, gconf.ErrNotFound) {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := GetRedisConfigE("bad.json"); !errors.As(err, &ce) || ce.AppId != "redis-e" || ce.Key != "bad.json" {
+		t.Errorf("unexpected error %v", err)
+	}
+	for key, field := range map[string]string{"type.json": "type", "sentinel.json": "sentinel.master"} {
+		config, err := GetRedisConfigE(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = config.NewClientE()
+		if !errors.As(err, &ce) || ce.Key != key || ce.Field != field {
+			t.Errorf("%s: unexpected error %v", key, err)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("GetRedisConfig should panic")
+		}
+	}()
+	GetRedisConfig("missing.json")
+}
+
+func TestNewUniversalClient(t *testing.T) {
+	cluster := &RedisConfig{Type: RedisCluster, Password: "pwd", Cluster: RedisClusterConfig{
+		Nodes: "10.0.0.1:7000, 10.0.0.2:7000,", MaxRedirects: 5, RouteByLatency: true}}
+	client := cluster.NewUniversalClient()
+	defer client.Close()
+	c, ok := client.(*redis.ClusterClient)
+	if !ok {
+		t.Fatalf("unexpected client %T", client)
+	}
+	if opt := c.Options(); !slices.Equal(opt.Addrs, []string{"10.0.0.1:7000", "10.0.0.2:7000"}) ||
+		opt.MaxRedirects != 5 || !opt.RouteByLatency || opt.Password != "pwd" {
+		t.Errorf("unexpected options %+v", opt)
+	}
+	if _, err := cluster.NewClientE(); err == nil {
+		t.Error("NewClientE should reject cluster")
+	}
+
+	sentinel := &RedisConfig{Type: RedisSentinel, Sentinel: RedisSentinelConfig{
+		Master: "mymaster", Nodes: "10.0.0.1:26379", Password: "sentinel-pwd", SlaveOnly: true}}
+	client = sentinel.NewUniversalClient()
+	if _, ok := client.(*redis.Client); !ok {
+		t.Errorf("unexpected client %T", client)
+	}
+	client.Close()
+	sentinel.Sentinel.RouteRandomly = true
+	client = sentinel.NewUniversalClient()
+	if c, ok := client.(*redis.ClusterClient); !ok || !c.Options().RouteRandomly {
+		t.Errorf("unexpected client %T", client)
+	}
+	client.Close()
+	sentinel.Db = 2
+	var ce *gconf.ConfigError
+	if _, err := sentinel.NewUniversalClientE(); !errors.As(err, &ce) || ce.Field != "db" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	standalone := &RedisConfig{Standalone: RedisStandaloneConfig{Host: "127.0.0.1", Port: 6380}, Db: 3}
+	client = standalone.NewUniversalClient()
+	defer client.Close()
+	if c, ok := client.(*redis.Client); !ok || c.Options().Addr != "127.0.0.1:6380" || c.Options().DB != 3 {
+		t.Errorf("unexpected client %T", client)
+	}
+
+	// 未配置host时与早期版本一致，连接本机
+	local := &RedisConfig{Standalone: RedisStandaloneConfig{Port: 6379}}
+	client = local.NewUniversalClient()
+	defer client.Close()
+	if c, ok := client.(*redis.Client); !ok || c.Options().Addr != ":6379" {
+		t.Errorf("unexpected client %T", client)
+	}
+}
+
+func selfSigned(t *testing.T) (certPEM, keyPEM string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.local"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func TestRedisOptions(t *testing.T) {
+	certPEM, keyPEM := selfSigned(t)
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "redis-ca.pem", certPEM)
+	c.SetValue(t, "app", "redis-cert.pem", certPEM)
+	c.SetValue(t, "app", "redis-key.pem", keyPEM)
+	collection := c.GetCurrentConfigCollection()
+
+	const options = `"poolSize":50,"minIdleConns":5,"maxRetries":5,"dialTimeout":"500ms","readTimeout":"1s","writeTimeout":"2s",
+		"tls":{"serverName":"redis.local","caKey":"redis-ca.pem","certKey":"redis-cert.pem","keyKey":"redis-key.pem"}`
+	check := func(name string, poolSize, minIdle, maxRetries int, dial, read, write time.Duration, tlsConfig *tls.Config) {
+		t.Helper()
+		if poolSize != 50 || minIdle != 5 || maxRetries != 5 || dial != 500*time.Millisecond || read != time.Second || write != 2*time.Second {
+			t.Errorf("%s: options not applied: %d %d %d %s %s %s", name, poolSize, minIdle, maxRetries, dial, read, write)
+		}
+		if tlsConfig == nil || tlsConfig.ServerName != "redis.local" || tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 {
+			t.Errorf("%s: tls not applied: %+v", name, tlsConfig)
+		}
+	}
+
+	for name, value := range map[string]string{
+		"standalone": `{"type":0,"standalone":{"host":"127.0.0.1","port":6379},` + options + `}`,
+		"sentinel":   `{"type":1,"sentinel":{"master":"m","nodes":"127.0.0.1:26379"},` + options + `}`,
+	} {
+		config, err := parseRedisConfig(collection, name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := config.NewClient()
+		opt := client.Options()
+		check(name, opt.PoolSize, opt.MinIdleConns, opt.MaxRetries, opt.DialTimeout, opt.ReadTimeout, opt.WriteTimeout, opt.TLSConfig)
+		client.Close()
+	}
+	config, err := parseRedisConfig(collection, "cluster", `{"type":2,"cluster":{"nodes":"127.0.0.1:7000"},`+options+`}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := config.NewUniversalClient().(*redis.ClusterClient)
+	opt := client.Options()
+	check("cluster", opt.PoolSize, opt.MinIdleConns, opt.MaxRetries, opt.DialTimeout, opt.ReadTimeout, opt.WriteTimeout, opt.TLSConfig)
+	client.Close()
+
+	var ce *gconf.ConfigError
+	if _, err := parseRedisConfig(collection, "bad", `{"dialTimeout":500}`); !errors.As(err, &ce) || ce.Key != "bad" {
+		t.Errorf("numeric duration should fail: %v", err)
+	}
+	for field, value := range map[string]string{
+		"tls.caKey":   `{"standalone":{"host":"h"},"tls":{"caKey":"missing.pem"}}`,
+		"tls.certKey": `{"standalone":{"host":"h"},"tls":{"certKey":"redis-cert.pem"}}`,
+	} {
+		config, _ := parseRedisConfig(collection, "tls", value)
+		if _, err := config.NewClientE(); !errors.As(err, &ce) || ce.Field != field || ce.AppId != "app" {
+			t.Errorf("%s: unexpected error %v", field, err)
+		}
+	}
+}
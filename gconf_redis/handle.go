@@ -0,0 +1,249 @@
+package gconf_redis
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/internal/swap"
+)
+
+const defaultDrainDelay = 5 * time.Second
+
+type options struct {
+	logger     *slog.Logger
+	drainDelay time.Duration
+}
+
+type Option func(*options)
+
+// 指定日志输出，默认使用slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// 切换客户端后等待多久关闭旧客户端，默认5s。期间已取得旧客户端的调用方仍可发出命令，
+// 关闭时等待执行中的命令完成，最多再等待d，之后强制关闭。
+// Subscribe等发布订阅连接不计入执行中的命令，会在d后随旧客户端关闭，订阅方需通过Client()重新订阅
+func WithDrainDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.drainDelay = d
+	}
+}
+
+// 记录执行中命令数量的客户端
+type trackedClient struct {
+	redis.UniversalClient
+
+	mux      sync.Mutex
+	inflight int
+	idle     chan struct{} // 执行中的命令全部完成时关闭
+}
+
+var _ redis.Hook = (*trackedClient)(nil)
+
+var closedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+func (t *trackedClient) begin() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.inflight == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.inflight++
+}
+
+func (t *trackedClient) end() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.inflight--
+	if t.inflight == 0 {
+		close(t.idle)
+	}
+}
+
+// 执行中的命令全部完成时可读
+func (t *trackedClient) idleC() <-chan struct{} {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.inflight == 0 {
+		return closedChan
+	}
+	return t.idle
+}
+
+func (t *trackedClient) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	t.begin()
+	return ctx, nil
+}
+
+func (t *trackedClient) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	t.end()
+	return nil
+}
+
+func (t *trackedClient) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	t.begin()
+	return ctx, nil
+}
+
+func (t *trackedClient) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	t.end()
+	return nil
+}
+
+// 判断是否需要重建客户端：配置字段及TLS证书内容
+type fingerprint struct {
+	config   RedisConfig
+	material [3]string
+}
+
+func fingerprintOf(config *RedisConfig) fingerprint {
+	f := fingerprint{config: *config}
+	f.config.collection = nil
+	if t := config.TLS; t != nil {
+		tlsCopy := *t
+		f.config.TLS = &tlsCopy
+		for i, k := range []string{t.CAKey, t.CertKey, t.KeyKey} {
+			if k != "" {
+				f.material[i], _ = config.lookup(k)
+			}
+		}
+	}
+	return f
+}
+
+// 随配置自动切换的redis客户端。配置或TLS证书变化时建立新客户端并替换，
+// 旧客户端在保留期过后、执行中的命令完成时关闭，见WithDrainDelay。调用方应在每次使用时通过Client()获取客户端
+//
+//	h, err := gconf_redis.NewHandle(gconf.GetCurrentConfigCollection(), "redis-config.json")
+//	defer h.Close()
+//	h.Client().Get(ctx, "key")
+type Handle struct {
+	key    string
+	o      *options
+	logger *slog.Logger
+
+	clients *swap.Value[trackedClient]
+	config  atomic.Pointer[RedisConfig]
+
+	mux            sync.Mutex
+	fingerprint    fingerprint
+	tlsKeys        map[string]bool
+	removeListener func()
+}
+
+// 读取集合中key对应的redis配置并建立客户端，之后监听配置及TLS证书的变化
+func NewHandle(c *gconf.ConfigCollection, key string, opts ...Option) (*Handle, error) {
+	o := &options{drainDelay: defaultDrainDelay}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	h := &Handle{
+		key:    key,
+		o:      o,
+		logger: o.logger.With("component", "gconf_redis", "appId", c.AppId(), "key", key),
+	}
+	h.clients = swap.New(o.drainDelay, h.closeClient, h.logger)
+	// 先监听再读取，读取期间的变化不会丢失；reload在mux内读取配置，总是应用最新的值
+	h.removeListener = c.AddCollectionChangeListener(gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		h.mux.Lock()
+		relevant := key == h.key || h.tlsKeys[key]
+		h.mux.Unlock()
+		if !relevant {
+			return
+		}
+		if err := h.reload(c); err != nil {
+			h.logger.Warn("reload redis config failed, keep previous", "changedKey", key, "error", err)
+		}
+	}))
+	if err := h.reload(c); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// 当前的客户端
+func (h *Handle) Client() redis.UniversalClient {
+	return h.clients.Load().UniversalClient
+}
+
+// 当前生效的redis配置，调用方不应修改
+func (h *Handle) Config() *RedisConfig {
+	return h.config.Load()
+}
+
+// 立即关闭当前及待关闭的客户端，不再响应配置变化
+func (h *Handle) Close() error {
+	h.removeListener()
+	return h.clients.Close()
+}
+
+func (h *Handle) reload(c *gconf.ConfigCollection) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	value, err := c.Lookup(h.key)
+	if err != nil {
+		return err
+	}
+	config, err := parseRedisConfig(c, h.key, value)
+	if err != nil {
+		return err
+	}
+	f := fingerprintOf(config)
+	old := h.clients.Load()
+	if old != nil && reflect.DeepEqual(f, h.fingerprint) {
+		return nil
+	}
+	client, err := config.NewUniversalClientE()
+	if err != nil {
+		return err
+	}
+	tracked := &trackedClient{UniversalClient: client}
+	client.AddHook(tracked)
+	if !h.clients.Swap(tracked) {
+		return client.Close() // 已关闭
+	}
+	h.config.Store(config)
+	h.fingerprint = f
+	h.tlsKeys = map[string]bool{}
+	if t := config.TLS; t != nil {
+		for _, k := range []string{t.CAKey, t.CertKey, t.KeyKey} {
+			if k != "" {
+				h.tlsKeys[k] = true
+			}
+		}
+	}
+	if old != nil {
+		h.logger.Info("redis config changed, switched to new client", "drainDelay", h.o.drainDelay)
+	}
+	return nil
+}
+
+// 等待执行中的命令完成后关闭客户端，最多等待drainDelay，Handle关闭时立即关闭
+func (h *Handle) closeClient(t *trackedClient) error {
+	timer := time.NewTimer(h.o.drainDelay)
+	defer timer.Stop()
+	select {
+	case <-t.idleC():
+	case <-h.clients.Done():
+	case <-timer.C:
+		h.logger.Warn("previous client still busy, closing")
+	}
+	return t.Close()
+}
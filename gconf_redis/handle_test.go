@@ -0,0 +1,133 @@
+package gconf_redis
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/guanaitong/gconf-go-client/gconftest"
+)
+
+func isClosed(client redis.UniversalClient) bool {
+	err := client.Ping(context.Background()).Err()
+	return err == redis.ErrClosed
+}
+
+func waitClosed(t *testing.T, client redis.UniversalClient) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !isClosed(client) {
+		if time.Now().After(deadline) {
+			t.Fatal("previous client not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "redis-config.json", `{"standalone":{"host":"127.0.0.1","port":1}}`)
+	h, err := NewHandle(c.GetCurrentConfigCollection(), "redis-config.json", WithDrainDelay(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	first := h.clients.Load()
+
+	// 格式变化但配置不变时不重建
+	c.SetValue(t, "app", "redis-config.json", `{"standalone": {"host": "127.0.0.1", "port": 1}, "unknown": true}`)
+	if h.clients.Load() != first {
+		t.Fatal("client should not be rebuilt")
+	}
+
+	c.SetValue(t, "app", "redis-config.json", `{"standalone":{"host":"127.0.0.1","port":2}}`)
+	second := h.Client()
+	if second == first.UniversalClient || second.(*redis.Client).Options().Addr != "127.0.0.1:2" {
+		t.Fatal("client should be rebuilt")
+	}
+	waitClosed(t, first)
+
+	// 命令一直未完成时，再等待一个保留期后强制关闭
+	busy := h.clients.Load()
+	busy.begin()
+	c.SetValue(t, "app", "redis-config.json", `{"standalone":{"host":"127.0.0.1","port":3}}`)
+	waitClosed(t, busy)
+	c.SetValue(t, "app", "redis-config.json", `{"standalone":{"host":"127.0.0.1","port":2}}`)
+	second = h.Client()
+
+	// 配置无效时保留原客户端
+	c.SetValue(t, "app", "redis-config.json", `{"type":9}`)
+	if h.Client() != second || h.Config().Standalone.Port != 2 {
+		t.Error("invalid config should keep previous client")
+	}
+}
+
+func TestHandleWaitsForCommands(t *testing.T) {
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "redis-config.json", `{"standalone":{"host":"127.0.0.1","port":1}}`)
+	h, err := NewHandle(c.GetCurrentConfigCollection(), "redis-config.json", WithDrainDelay(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// 保留期为1小时，关闭只能由命令完成触发
+	client := h.clients.Load()
+	client.begin()
+	closed := make(chan struct{})
+	go func() {
+		h.closeClient(client)
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("client closed with commands in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	client.end()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("client not closed after commands finished")
+	}
+	if !isClosed(client) {
+		t.Error("client should be closed")
+	}
+}
+
+func TestHandleTLSMaterial(t *testing.T) {
+	certPEM, _ := selfSigned(t)
+	otherPEM, _ := selfSigned(t)
+	c := gconftest.NewClient(t, "app")
+	c.SetValue(t, "app", "ca.pem", certPEM)
+	c.SetValue(t, "app", "redis-config.json", `{"standalone":{"host":"127.0.0.1","port":1},"tls":{"caKey":"ca.pem"}}`)
+	h, err := NewHandle(c.GetCurrentConfigCollection(), "redis-config.json", WithDrainDelay(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := h.Client()
+	c.SetValue(t, "app", "unrelated", "x")
+	if h.Client() != first {
+		t.Fatal("unrelated change should not rebuild")
+	}
+	c.SetValue(t, "app", "ca.pem", otherPEM)
+	if h.Client() == first {
+		t.Fatal("CA change should rebuild")
+	}
+	c.SetValue(t, "app", "ca.pem", "not a certificate")
+	current := h.Client()
+	if current.(*redis.Client).Options().TLSConfig == nil {
+		t.Error("tls config lost")
+	}
+
+	// Close立即关闭待关闭的客户端
+	h.Close()
+	if !isClosed(first) || !isClosed(current) {
+		t.Error("clients should be closed")
+	}
+	if _, err := NewHandle(c.GetCurrentConfigCollection(), "missing.json"); err == nil || !strings.Contains(err.Error(), "missing.json") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
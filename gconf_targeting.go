@@ -0,0 +1,166 @@
+package gconf
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"path"
+	"slices"
+)
+
+// 集合中定向规则的key，启用WithProfiles时生效。规则命中时对应的profile在本集合中生效，优先级高于labels及区域：
+//
+//	{"rules": [{"profile": "gray", "percentage": 10, "regions": ["prod-sh"]}]}
+//
+// 配合timeout@gray.properties，prod-sh约10%的实例使用灰度配置
+const TargetingKey = "gconf-targeting.json"
+
+// 定向规则，所有设置的条件均满足时命中。Instances与Percentage同时设置时满足其一即可
+type TargetingRule struct {
+	Profile    string   `json:"profile"`
+	Instances  []string `json:"instances,omitempty"`  // 实例名，语法同path.Match
+	Percentage *float64 `json:"percentage,omitempty"` // 0-100，按实例的稳定分桶命中
+	Regions    []string `json:"regions,omitempty"`
+	Labels     []string `json:"labels,omitempty"` // 实例有任一label即满足
+	Salt       string   `json:"salt,omitempty"`   // 分桶的盐，默认为profile，修改后重新分桶
+}
+
+type targetingRules struct {
+	Rules []TargetingRule `json:"rules"`
+}
+
+// 规则对当前实例的评估结果
+type Assignment struct {
+	Profile string
+	Matched bool
+	Bucket  float64 // 实例在该规则下的分桶，[0, 100)
+}
+
+// key在salt下的稳定分桶，[0, 100)。实例定向规则及gconf_flags的百分比灰度共用，保证同一key分桶一致
+func Bucket(salt, key string) float64 {
+	sum := sha256.Sum256([]byte(salt + "/" + key))
+	return float64(binary.BigEndian.Uint64(sum[:8])%10000) / 100
+}
+
+func (c *Client) evaluate(rule TargetingRule) Assignment {
+	salt := rule.Salt
+	if salt == "" {
+		salt = rule.Profile
+	}
+	a := Assignment{Profile: rule.Profile, Bucket: Bucket(salt, c.appId+"-->"+c.instance)} //同一实例(应用名+实例名)重启后分桶不变
+	if len(rule.Regions) > 0 && !slices.Contains(rule.Regions, c.region) {
+		return a
+	}
+	if len(rule.Labels) > 0 && !slices.ContainsFunc(rule.Labels, func(l string) bool { return slices.Contains(c.labels, l) }) {
+		return a
+	}
+	instanceMatched := slices.ContainsFunc(rule.Instances, func(p string) bool {
+		ok, _ := path.Match(p, c.instance)
+		return ok
+	})
+	switch {
+	case rule.Percentage != nil:
+		a.Matched = instanceMatched || a.Bucket < *rule.Percentage
+	case len(rule.Instances) > 0:
+		a.Matched = instanceMatched
+	default:
+		a.Matched = true
+	}
+	return a
+}
+
+// 定向规则对当前实例的评估结果变化时调用，参数为变化前后的结果。
+// 在gconf后台同步goroutine里执行，此时覆盖配置已按新的结果生效，请不要阻塞
+type TargetingChangeListener func(oldAssignments, newAssignments []Assignment)
+
+// 监听定向规则评估结果的变化，如实例进入或退出灰度，返回的函数用于移除该监听器
+func (c *ConfigCollection) AddTargetingChangeListener(listener TargetingChangeListener) (remove func()) {
+	e := &listener
+	c.lisMux.Lock()
+	defer c.lisMux.Unlock()
+	c.targetingLis = append(c.targetingLis, e)
+	return func() {
+		c.lisMux.Lock()
+		defer c.lisMux.Unlock()
+		c.targetingLis = without(c.targetingLis, e)
+	}
+}
+
+func (c *ConfigCollection) fireTargetingChanged(oldAssignments, newAssignments []Assignment) {
+	c.lisMux.RLock()
+	listeners := append([]*TargetingChangeListener(nil), c.targetingLis...)
+	c.lisMux.RUnlock()
+	for _, listener := range listeners {
+		c.callListener(ConfigChangeListenerFunc(func(string, string, string) {
+			(*listener)(oldAssignments, newAssignments)
+		}), TargetingKey, "", "")
+	}
+}
+
+// 按定向规则更新本集合生效的profile，规则无法解析时保留原结果。
+// 返回变化前的评估结果，未变化时changed为false
+func (c *ConfigCollection) refreshTargeting() (old []Assignment, changed bool) {
+	c.dataMux.RLock()
+	v := c.data[TargetingKey]
+	c.dataMux.RUnlock()
+	var rules targetingRules
+	if v != nil && !v.isRemoved() {
+		if err := json.Unmarshal([]byte(v.Template()), &rules); err != nil {
+			c.client.logger.Warn("invalid targeting rules", "appId", c.appId, "error", err)
+			return nil, false
+		}
+	}
+	var assignments []Assignment
+	var targeted []string
+	for _, rule := range rules.Rules {
+		if rule.Profile == "" {
+			continue
+		}
+		a := c.client.evaluate(rule)
+		assignments = append(assignments, a)
+		if a.Matched && !slices.Contains(targeted, a.Profile) {
+			targeted = append(targeted, a.Profile)
+		}
+	}
+
+	c.dataMux.Lock()
+	oldTargeted := c.targeted
+	old = c.assignments
+	c.targeted, c.assignments = targeted, assignments
+	c.dataMux.Unlock()
+	if !slices.Equal(oldTargeted, targeted) {
+		c.client.logger.Info("targeting changed", "appId", c.appId, "from", oldTargeted, "to", targeted)
+	}
+	return old, !slices.Equal(old, assignments)
+}
+
+// 本集合生效的profile，按优先级从高到低，调用方需持有dataMux
+func (c *ConfigCollection) profilesLocked() []string {
+	if len(c.targeted) == 0 {
+		return c.client.profiles
+	}
+	res := append([]string{}, c.targeted...)
+	for _, p := range c.client.profiles {
+		if !slices.Contains(res, p) {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// 本集合生效的profile，按优先级从高到低，包括定向规则命中的profile。未启用WithProfiles时返回nil
+func (c *ConfigCollection) Profiles() []string {
+	c.dataMux.RLock()
+	defer c.dataMux.RUnlock()
+	if c.client.profiles == nil {
+		return nil
+	}
+	return append([]string{}, c.profilesLocked()...)
+}
+
+// 定向规则对当前实例的评估结果，按规则顺序排列
+func (c *ConfigCollection) Assignments() []Assignment {
+	c.dataMux.RLock()
+	defer c.dataMux.RUnlock()
+	return append([]Assignment(nil), c.assignments...)
+}
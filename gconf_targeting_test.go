@@ -0,0 +1,118 @@
+package gconf
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBucketOf(t *testing.T) {
+	if Bucket("gray", "app-->pod-1") != Bucket("gray", "app-->pod-1") {
+		t.Fatal("bucket is not stable")
+	}
+	hit := 0
+	for i := 0; i < 10000; i++ {
+		if b := Bucket("gray", fmt.Sprintf("app-->pod-%d", i)); b < 0 || b >= 100 {
+			t.Fatalf("bucket out of range: %v", b)
+		} else if b < 10 {
+			hit++
+		}
+	}
+	if hit < 800 || hit > 1200 {
+		t.Errorf("expected about 10%% of instances in the first 10 buckets, got %d", hit)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	c := &Client{appId: "app", instance: "pod-1", region: "prod-sh", labels: []string{"canary"}}
+	pct := func(p float64) *float64 { return &p }
+	for _, tc := range []struct {
+		rule TargetingRule
+		want bool
+	}{
+		{TargetingRule{Profile: "a"}, true},
+		{TargetingRule{Profile: "a", Regions: []string{"test-ali"}}, false},
+		{TargetingRule{Profile: "a", Regions: []string{"prod-sh"}, Labels: []string{"blue", "canary"}}, true},
+		{TargetingRule{Profile: "a", Labels: []string{"blue"}}, false},
+		{TargetingRule{Profile: "a", Instances: []string{"pod-*"}}, true},
+		{TargetingRule{Profile: "a", Instances: []string{"pod-2"}}, false},
+		{TargetingRule{Profile: "a", Percentage: pct(100)}, true},
+		{TargetingRule{Profile: "a", Percentage: pct(0)}, false},
+		{TargetingRule{Profile: "a", Percentage: pct(0), Instances: []string{"pod-1"}}, true},
+		{TargetingRule{Profile: "a", Percentage: pct(100), Regions: []string{"test-ali"}}, false},
+	} {
+		if got := c.evaluate(tc.rule); got.Matched != tc.want {
+			t.Errorf("evaluate(%+v) = %v, want %v", tc.rule, got.Matched, tc.want)
+		}
+	}
+}
+
+func TestTargeting(t *testing.T) {
+	t.Setenv("APP_INSTANCE_NAME", "pod-1")
+	p := NewMemoryProvider()
+	p.SetCollection("app", map[string]string{
+		"timeout.properties":      "timeout=1",
+		"timeout@gray.properties": "timeout=2",
+		TargetingKey:              `{"rules":[{"profile":"gray","instances":["pod-*"]}]}`,
+	})
+	c := NewClient("app", WithMemoryProvider(p), WithRegion("prod-sh"), WithProfiles())
+	defer c.Close()
+	collection := c.GetCurrentConfigCollection()
+
+	if got := collection.GetValue("timeout.properties").Raw(); got != "timeout=2" {
+		t.Fatalf("targeted overlay not applied: %q", got)
+	}
+	if got := collection.Profiles(); !reflect.DeepEqual(got, []string{"gray", "prod-sh"}) {
+		t.Errorf("unexpected profiles %v", got)
+	}
+	if got := collection.Explain("timeout.properties"); got.Winner != "timeout@gray.properties" || !got.Overlays[0].Active {
+		t.Errorf("unexpected resolution %+v", got)
+	}
+	if a := collection.Assignments(); len(a) != 1 || !a[0].Matched || a[0].Profile != "gray" {
+		t.Errorf("unexpected assignments %+v", a)
+	}
+
+	var changes, events []string
+	collection.AddConfigChangeListener("timeout.properties", ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		changes = append(changes, oldValue+" -> "+newValue)
+	}))
+	collection.AddTargetingChangeListener(func(oldAssignments, newAssignments []Assignment) {
+		events = append(events, fmt.Sprintf("%v -> %v", matchedOf(oldAssignments), matchedOf(newAssignments)))
+	})
+	setRules := func(rules string) {
+		t.Helper()
+		p.Set("app", TargetingKey, rules)
+		if err := c.Refresh(context.Background(), "app"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setRules(`{"rules":[{"profile":"gray","instances":["pod-2"]}]}`)
+	setRules(`not json`) //无法解析时保留原结果
+	setRules(`{"rules":[{"profile":"gray","percentage":100}]}`)
+	if want := []string{"timeout=2 -> timeout=1", "timeout=1 -> timeout=2"}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+
+	if want := []string{"[gray] -> []", "[] -> [gray]"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+
+	// 命中的profile没有覆盖配置时同样通知
+	changes, events = nil, nil
+	setRules(`{"rules":[{"profile":"gray","percentage":100},{"profile":"canary","percentage":100}]}`)
+	setRules(`{"rules":[{"profile":"gray","percentage":100},{"profile":"canary","percentage":100}]}`)
+	if want := []string{"[gray] -> [gray canary]"}; !reflect.DeepEqual(events, want) || len(changes) != 0 {
+		t.Errorf("events = %q, want %q, changes %q", events, want, changes)
+	}
+}
+
+func matchedOf(assignments []Assignment) []string {
+	res := []string{}
+	for _, a := range assignments {
+		if a.Matched {
+			res = append(res, a.Profile)
+		}
+	}
+	return res
+}
@@ -1,14 +1,58 @@
-package gconf
+package gconf_test
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+	"github.com/guanaitong/gconf-go-client/gconftest"
 )
 
-func init() {
-	Init("userdoor")
+var server *gconftest.Server
+
+const plainPassword = "gconf-test-password"
+
+func TestMain(m *testing.M) {
+	server = gconftest.NewServer()
+	server.SetCollection("impower", map[string]string{
+		"deny.properties": "path=/tmp/impower\ncmd=date122\nperiod=10\nhas=true",
+	})
+	server.SetCollection("userdoor", map[string]string{"a": "b"})
+	server.SetCollection("golang", map[string]string{"publicKey": publicKey()})
+	gconf.Init("userdoor", gconf.WithEndpoints(server.URL))
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+var privateKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+func publicKey() string {
+	bs, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(bs)
+}
+
+// 与gconf服务端一致，使用私钥加密(PKCS#1 v1.5签名填充)
+func encryptPassword(pwd string) string {
+	bs, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.Hash(0), []byte(pwd))
+	if err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(bs)
 }
 
 type es struct {
@@ -26,7 +70,7 @@ type impower struct {
 
 func TestGetConfigCollection(t *testing.T) {
 	t.Log("[impower]-------------------------------")
-	d1 := GetConfigCollection("impower")
+	d1 := gconf.GetConfigCollection("impower")
 	t.Log(d1, d1.AsMap())
 
 	dm1 := d1.GetValue("deny.properties")
@@ -39,16 +83,63 @@ func TestGetConfigCollection(t *testing.T) {
 		t.Error(errors.New("not same from server"))
 	}
 	t.Log("[golang]------------------------------")
-	if GetGlobalConfigCollection() == nil {
+	if gconf.GetGlobalConfigCollection() == nil {
 		t.Fail()
 	}
-	c := GetConfigCollection("userdoor")
-	c1 := GetCurrentConfigCollection()
+	c := gconf.GetConfigCollection("userdoor")
+	c1 := gconf.GetCurrentConfigCollection()
 	if c != c1 {
 		t.FailNow()
 	}
 }
 
+func TestGetConfigCollectionConcurrently(t *testing.T) {
+	s := gconftest.NewServer()
+	defer s.Close()
+	s.SetCollection("app", map[string]string{"a": "1"})
+	s.SetCollection("slow", map[string]string{"b": "2"})
+	c := gconf.NewClient("app", gconf.WithEndpoints(s.URL), gconf.WithProbeInterval(0))
+	defer c.Close()
+	current := c.GetCurrentConfigCollection()
+
+	s.SetLatency("/getConfigApp", time.Millisecond*300)
+	s.ResetRequests()
+	var wg sync.WaitGroup
+	res := make([]*gconf.ConfigCollection, 3)
+	for i := range res {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res[i] = c.GetConfigCollection("slow")
+		}(i)
+	}
+	time.Sleep(time.Millisecond * 50)
+
+	// 获取其他集合时不阻塞已加载的集合
+	start := time.Now()
+	if c.GetCurrentConfigCollection() != current || !c.Ready() {
+		t.Error("unexpected collection or ready state")
+	}
+	if d := time.Since(start); d > time.Millisecond*100 {
+		t.Errorf("blocked by loading collection for %v", d)
+	}
+	wg.Wait()
+	if res[0] == nil || res[1] != res[0] || res[2] != res[0] || res[0].GetValue("b").Raw() != "2" {
+		t.Fatalf("unexpected collections %v", res)
+	}
+	if n := len(s.Requests("/getConfigApp")); n != 1 {
+		t.Errorf("expected 1 getConfigApp request, got %d", n)
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	long := strings.Repeat("x", 10000) //超过bufio默认缓冲的行
+	got := gconf.ParseProperties("# comment\n!note\na=1\nb=x=y\nlong=" + long + "\nnokv")
+	if want := map[string]string{"a": "1", "b": "x=y", "long": long}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected properties %v", got)
+	}
+}
+
 type testBean struct {
 	a string `config:"a"`
 	b int
@@ -73,9 +164,9 @@ func TestReflectBase(t *testing.T) {
 }
 
 func TestDecrypt(t *testing.T) {
-	epwd := "E+lDullrAU/qV1MVqR7L0GrbBkFHWaftsKTVni3ooL90/PZyH/VpcKF/HqJqzAyzoHI8vR+tawW/kE5sgRcpVkYivugNhWhEtnQpbRNjvnkCd8OcyuhjEVnrzDg4iNtJ4+RWKq37vb4aXU1/skmXDLd1Jf2ZNYndzTgHM1EbP6Ac0KqWzpeS4o2QxtX4E1nzdrxCOtEYtTewtXiaxA4kHdVb6fIkLa/OvY2xDNOQZKhlw9IU6LC3Ypq8qqQPq1dCW+Y/TzktZcbKVmQ0aHchPLuWpiO2VNwojHu7hiD7ZiNsELiDvose8iNNSwwpfTKbIODqjtgBrRWD/VLjCbMcxg=="
-	pwd := Decrypt(epwd)
-	if pwd == "" {
+	epwd := encryptPassword(plainPassword)
+	pwd := gconf.Decrypt(epwd)
+	if pwd != plainPassword {
 		t.Error("error")
 	}
 	fmt.Println(pwd)
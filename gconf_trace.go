@@ -0,0 +1,27 @@
+package gconf
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/guanaitong/gconf-go-client"
+
+var (
+	attrAppId       = attribute.Key("gconf.app_id")
+	attrPath        = attribute.Key("gconf.path")
+	attrEndpoint    = attribute.Key("gconf.endpoint")
+	attrAttempts    = attribute.Key("gconf.attempts")
+	attrKeysChanged = attribute.Key("gconf.keys_changed")
+	attrStatusCode  = attribute.Key("http.response.status_code")
+)
+
+// 结束span，err不为空时记录错误
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
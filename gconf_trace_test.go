@@ -0,0 +1,146 @@
+package gconf
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// 记录结束的span，代替otel sdk，避免仅为测试引入依赖
+type spanRecorder struct {
+	embedded.TracerProvider
+
+	mux   sync.Mutex
+	ended []*recordedSpan
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	recorder *spanRecorder
+}
+
+type recordedSpan struct {
+	embedded.Span
+	recorder *spanRecorder
+	name     string
+	sc       trace.SpanContext
+	parent   trace.SpanContext
+	attrs    []attribute.KeyValue
+}
+
+func (r *spanRecorder) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{recorder: r}
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	parent := trace.SpanContextFromContext(ctx)
+	scc := trace.SpanContextConfig{TraceID: parent.TraceID(), TraceFlags: trace.FlagsSampled}
+	if !scc.TraceID.IsValid() {
+		rand.Read(scc.TraceID[:])
+	}
+	rand.Read(scc.SpanID[:])
+	s := &recordedSpan{recorder: t.recorder, name: name, sc: trace.NewSpanContext(scc), parent: parent, attrs: cfg.Attributes()}
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (r *spanRecorder) Ended() []*recordedSpan {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([]*recordedSpan(nil), r.ended...)
+}
+
+func (s *recordedSpan) End(options ...trace.SpanEndOption) {
+	s.recorder.mux.Lock()
+	defer s.recorder.mux.Unlock()
+	s.recorder.ended = append(s.recorder.ended, s)
+}
+
+func (s *recordedSpan) AddEvent(name string, options ...trace.EventOption) {}
+
+func (s *recordedSpan) AddLink(link trace.Link) {}
+
+func (s *recordedSpan) IsRecording() bool {
+	return true
+}
+
+func (s *recordedSpan) RecordError(err error, options ...trace.EventOption) {}
+
+func (s *recordedSpan) SpanContext() trace.SpanContext {
+	return s.sc
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {}
+
+func (s *recordedSpan) SetName(name string) {
+	s.name = name
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.recorder.mux.Lock()
+	defer s.recorder.mux.Unlock()
+	s.attrs = append(s.attrs, kv...)
+}
+
+func (s *recordedSpan) TracerProvider() trace.TracerProvider {
+	return s.recorder
+}
+
+func TestTracing(t *testing.T) {
+	var traced int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("traceparent") != "" {
+			atomic.AddInt32(&traced, 1)
+		}
+		switch r.URL.Path {
+		case "/getConfigApp":
+			w.Write([]byte(`{"configCollectionId":"app","name":"app"}`))
+		case "/listConfigs":
+			w.Write([]byte(`{"a":"1"}`))
+		case "/watch":
+			time.Sleep(time.Millisecond * 20)
+		}
+	}))
+	defer server.Close()
+
+	recorder := &spanRecorder{}
+	c := NewClient("app", WithEndpoints(server.URL), WithTracerProvider(recorder))
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&traced) == 0 {
+		t.Fatal("trace context should be propagated")
+	}
+
+	spans := map[string]*recordedSpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.name] = s
+	}
+	for _, name := range []string{"gconf.getConfigCollection", "gconf.refreshData", "gconf.getContent", "gconf.request"} {
+		if _, ok := spans[name]; !ok {
+			t.Fatalf("missing span %s", name)
+		}
+	}
+	refresh := spans["gconf.refreshData"]
+	if refresh.parent.SpanID() != spans["gconf.getConfigCollection"].sc.SpanID() {
+		t.Fatal("refresh should be a child of getConfigCollection")
+	}
+	attrs := map[string]any{}
+	for _, kv := range refresh.attrs {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attrs["gconf.app_id"] != "app" || attrs["gconf.keys_changed"] != int64(1) {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
@@ -7,7 +7,13 @@ import (
 	"math/big"
 )
 
+// 使用默认客户端解密，见Client.Decrypt
 func Decrypt(encryptedPassword string) string {
+	return std.Decrypt(encryptedPassword)
+}
+
+// 使用全局配置集合中的publicKey解密gconf加密的密码，失败时返回""
+func (c *Client) Decrypt(encryptedPassword string) string {
 	if encryptedPassword == "" {
 		return ""
 	}
@@ -15,7 +21,7 @@ func Decrypt(encryptedPassword string) string {
 	if err != nil {
 		return ""
 	}
-	publicKey := GetGlobalConfigCollection().GetValue("publicKey").Raw()
+	publicKey := c.GetGlobalConfigCollection().GetValue("publicKey").Raw()
 	key, err := base64.StdEncoding.DecodeString(publicKey)
 	if err != nil {
 		return ""
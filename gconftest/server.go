@@ -0,0 +1,298 @@
+// Package gconftest 提供测试用的gconf服务端，无需连接真实的gconf即可测试依赖配置的代码。
+//
+//	s := gconftest.NewServer()
+//	defer s.Close()
+//	s.SetCollection("app", map[string]string{"timeout.properties": "timeout=3"})
+//	c := gconf.NewClient("app", gconf.WithEndpoints(s.URL))
+//	defer c.Close()
+package gconftest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultWatchTimeout = time.Second * 30
+
+// 服务端收到的请求
+type Request struct {
+	Path   string
+	Params url.Values
+	Time   time.Time
+}
+
+type collection struct {
+	name    string
+	data    map[string]string
+	version int64
+}
+
+type failure struct {
+	statusCode int
+	times      int
+}
+
+// 基于httptest的gconf服务端，实现/getConfigApp、/listConfigs、/listConfigKeys、/getConfig及/watch长轮询
+type Server struct {
+	*httptest.Server
+
+	mux          sync.Mutex
+	changed      chan struct{} // 配置变化时关闭并重建，用于唤醒watch
+	collections  map[string]*collection
+	seen         map[string]map[string]int64 // clientId -> appId -> 已通知的版本
+	failures     map[string]*failure
+	latency      map[string]time.Duration
+	watchTimeout time.Duration
+	requests     []Request
+}
+
+// 创建并启动服务端，使用完毕需调用Close
+func NewServer() *Server {
+	s := &Server{
+		changed:      make(chan struct{}),
+		collections:  map[string]*collection{},
+		seen:         map[string]map[string]int64{},
+		failures:     map[string]*failure{},
+		latency:      map[string]time.Duration{},
+		watchTimeout: defaultWatchTimeout,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// 创建或替换配置集合
+func (s *Server) SetCollection(appId string, data map[string]string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.collections[appId]
+	if !ok {
+		c = &collection{name: appId}
+		s.collections[appId] = c
+	}
+	c.data = make(map[string]string, len(data))
+	for k, v := range data {
+		c.data[k] = v
+	}
+	s.changedLocked(c)
+}
+
+// 删除配置集合，之后获取该集合返回404
+func (s *Server) RemoveCollection(appId string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.collections, appId)
+}
+
+// 设置单个配置项，集合不存在时自动创建，并唤醒监听该集合的watch
+func (s *Server) Set(appId, key, value string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	c, ok := s.collections[appId]
+	if !ok {
+		c = &collection{name: appId, data: map[string]string{}}
+		s.collections[appId] = c
+	}
+	c.data[key] = value
+	s.changedLocked(c)
+}
+
+// 删除单个配置项，并唤醒监听该集合的watch
+func (s *Server) Delete(appId, key string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if c, ok := s.collections[appId]; ok {
+		delete(c.data, key)
+		s.changedLocked(c)
+	}
+}
+
+// 返回配置项的当前值
+func (s *Server) Get(appId, key string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if c, ok := s.collections[appId]; ok {
+		v, ok := c.data[key]
+		return v, ok
+	}
+	return "", false
+}
+
+func (s *Server) changedLocked(c *collection) {
+	c.version++
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// 接下来times次请求path时返回statusCode，path为空时对所有请求生效
+func (s *Server) FailNext(path string, statusCode, times int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failures[path] = &failure{statusCode: statusCode, times: times}
+}
+
+// 请求path时延迟d再响应，path为空时对所有请求生效，d为0时取消
+func (s *Server) SetLatency(path string, d time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.latency[path] = d
+}
+
+// watch在没有变化时的最长等待时间，默认30秒
+func (s *Server) SetWatchTimeout(d time.Duration) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.watchTimeout = d
+}
+
+// 返回收到的请求，path不为空时只返回该path的请求
+func (s *Server) Requests(path string) []Request {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var res []Request
+	for _, r := range s.requests {
+		if path == "" || r.Path == path {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// 清空请求记录
+func (s *Server) ResetRequests() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	params := r.URL.Query()
+
+	s.mux.Lock()
+	s.requests = append(s.requests, Request{Path: path, Params: params, Time: time.Now()})
+	latency := s.latency[""] + s.latency[path]
+	statusCode := s.takeFailureLocked(path)
+	s.mux.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if statusCode != 0 {
+		w.WriteHeader(statusCode)
+		return
+	}
+
+	appId := params.Get("configAppId")
+	switch path {
+	case "/getConfigApp":
+		s.withCollection(w, appId, func(c *collection) any {
+			return map[string]string{"configCollectionId": appId, "name": c.name}
+		})
+	case "/listConfigs":
+		s.withCollection(w, appId, func(c *collection) any {
+			return c.data
+		})
+	case "/listConfigKeys":
+		s.withCollection(w, appId, func(c *collection) any {
+			keys := make([]string, 0, len(c.data))
+			for k := range c.data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return keys
+		})
+	case "/getConfig":
+		s.mux.Lock()
+		var value string
+		if c, ok := s.collections[appId]; ok {
+			value = c.data[params.Get("key")]
+		}
+		s.mux.Unlock()
+		w.Write([]byte(value))
+	case "/watch":
+		s.watch(w, r, strings.Split(params.Get("configAppIdList"), ","), params.Get("clientId"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) takeFailureLocked(path string) int {
+	for _, p := range []string{path, ""} {
+		if f, ok := s.failures[p]; ok && f.times > 0 {
+			f.times--
+			return f.statusCode
+		}
+	}
+	return 0
+}
+
+// 集合存在时以json返回f的结果，否则返回404
+func (s *Server) withCollection(w http.ResponseWriter, appId string, f func(c *collection) any) {
+	s.mux.Lock()
+	c, ok := s.collections[appId]
+	var bs []byte
+	if ok {
+		bs, _ = json.Marshal(f(c))
+	}
+	s.mux.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bs)
+}
+
+// 长轮询：返回客户端上次watch后版本变化的appId列表，没有变化时等待，超时返回空。
+// 客户端首次watch某集合时直接返回该集合，避免遗漏客户端加载与首次watch之间的变化
+func (s *Server) watch(w http.ResponseWriter, r *http.Request, appIds []string, clientId string) {
+	s.mux.Lock()
+	timeout := time.NewTimer(s.watchTimeout)
+	s.mux.Unlock()
+	defer timeout.Stop()
+	for {
+		s.mux.Lock()
+		seen, ok := s.seen[clientId]
+		if !ok {
+			seen = map[string]int64{}
+			s.seen[clientId] = seen
+		}
+		var changedAppIds []string
+		for _, appId := range appIds {
+			c, ok := s.collections[appId]
+			if !ok {
+				continue
+			}
+			if v, ok := seen[appId]; !ok || v != c.version {
+				changedAppIds = append(changedAppIds, appId)
+			}
+			seen[appId] = c.version
+		}
+		changed := s.changed
+		s.mux.Unlock()
+
+		if len(changedAppIds) > 0 {
+			bs, _ := json.Marshal(changedAppIds)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(bs)
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package gconftest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetCollection("app", map[string]string{"a": "1"})
+
+	c := gconf.NewClient("app", gconf.WithEndpoints(s.URL))
+	defer c.Close()
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	collection := c.GetCurrentConfigCollection()
+	if v := collection.GetValue("a").Raw(); v != "1" {
+		t.Fatalf("unexpected value %q", v)
+	}
+
+	s.Set("app", "a", "2")
+	deadline := time.Now().Add(time.Second * 5)
+	for collection.GetValue("a").Raw() != "2" {
+		if time.Now().After(deadline) {
+			t.Fatal("watch did not deliver the change")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if len(s.Requests("/watch")) == 0 {
+		t.Fatal("expected watch requests")
+	}
+	if r := s.Requests("/getConfigApp"); len(r) != 1 || r[0].Params.Get("configAppId") != "app" {
+		t.Fatalf("unexpected getConfigApp requests %+v", r)
+	}
+
+	if c.GetConfigCollection("missing") != nil {
+		t.Fatal("missing collection should be nil")
+	}
+}
+
+func TestServerFailures(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetCollection("app", map[string]string{"a": "1"})
+	s.FailNext("/listConfigs", http.StatusServiceUnavailable, 2)
+	s.SetLatency("/getConfigApp", time.Millisecond*50)
+
+	c := gconf.NewClient("app", gconf.WithEndpoints(s.URL))
+	defer c.Close()
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if err := c.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < time.Millisecond*50 {
+		t.Fatal("latency was not injected")
+	}
+	if n := len(s.Requests("/listConfigs")); n < 3 {
+		t.Fatalf("expected retries after failures, got %d requests", n)
+	}
+}
+
+func TestServerWatchTimeout(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetCollection("app", map[string]string{})
+	s.SetWatchTimeout(time.Millisecond * 50)
+
+	get := func() string {
+		resp, err := http.Get(s.URL + "/watch?configAppIdList=app&clientId=c1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		buf := make([]byte, 64)
+		n, _ := resp.Body.Read(buf)
+		return string(buf[:n])
+	}
+	if v := get(); v != `["app"]` {
+		t.Fatalf("first watch should report the collection, got %q", v)
+	}
+	if v := get(); v != "" {
+		t.Fatalf("watch without change should time out empty, got %q", v)
+	}
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		s.Set("app", "k", "v")
+	}()
+	s.SetWatchTimeout(time.Second * 5)
+	if v := get(); v != `["app"]` {
+		t.Fatalf("watch should return on change, got %q", v)
+	}
+}
@@ -0,0 +1,109 @@
+package gconftest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+// 基于内存配置源的客户端，用于在单个测试中覆盖配置
+type Client struct {
+	*gconf.Client
+	Provider *gconf.MemoryProvider
+}
+
+var (
+	mux       sync.Mutex
+	providers = map[*gconf.Client]*gconf.MemoryProvider{} // gconftest创建的客户端
+)
+
+// 创建使用内存配置源的客户端，测试结束时关闭。每个测试使用独立的客户端，可配合t.Parallel使用
+func NewClient(t testing.TB, appName string, opts ...gconf.Option) *Client {
+	t.Helper()
+	p := gconf.NewMemoryProvider()
+	p.SetCollection(appName, map[string]string{})
+	c := gconf.NewClient(appName, append(opts, gconf.WithMemoryProvider(p))...)
+	mux.Lock()
+	providers[c] = p
+	mux.Unlock()
+	t.Cleanup(func() {
+		c.Close()
+		mux.Lock()
+		delete(providers, c)
+		mux.Unlock()
+	})
+	return &Client{Client: c, Provider: p}
+}
+
+// 设置配置项并同步触发监听器，测试结束时恢复原值并再次触发监听器
+func (c *Client) SetValue(t testing.TB, appId, key, value string) {
+	t.Helper()
+	old, existed := c.Provider.Get(appId, key)
+	c.Provider.Set(appId, key, value)
+	c.refresh(t, appId)
+	t.Cleanup(func() { c.restore(t, appId, key, old, existed) })
+}
+
+// 删除配置项并同步触发监听器，测试结束时恢复
+func (c *Client) DeleteValue(t testing.TB, appId, key string) {
+	t.Helper()
+	old, existed := c.Provider.Get(appId, key)
+	if !existed {
+		return
+	}
+	c.Provider.Delete(appId, key)
+	c.refresh(t, appId)
+	t.Cleanup(func() { c.restore(t, appId, key, old, existed) })
+}
+
+func (c *Client) restore(t testing.TB, appId, key, old string, existed bool) {
+	if existed {
+		c.Provider.Set(appId, key, old)
+	} else {
+		c.Provider.Delete(appId, key)
+	}
+	c.refresh(t, appId)
+}
+
+func (c *Client) refresh(t testing.TB, appId string) {
+	t.Helper()
+	if err := c.Refresh(context.Background(), appId); err != nil {
+		t.Fatalf("gconftest: refresh %s: %v", appId, err)
+	}
+}
+
+// 在默认客户端上设置配置项，测试结束时恢复。
+// 默认客户端不是gconftest创建的时，先替换为内存客户端，测试结束时换回。
+// 会修改全局状态，不能在t.Parallel的测试中使用，并行测试请使用NewClient
+func SetValue(t testing.TB, appId, key, value string) {
+	t.Helper()
+	defaultClient(t).SetValue(t, appId, key, value)
+}
+
+// 在默认客户端上删除配置项，测试结束时恢复，限制同SetValue
+func DeleteValue(t testing.TB, appId, key string) {
+	t.Helper()
+	defaultClient(t).DeleteValue(t, appId, key)
+}
+
+func defaultClient(t testing.TB) *Client {
+	t.Helper()
+	prev := gconf.Default()
+	mux.Lock()
+	p, ok := providers[prev]
+	mux.Unlock()
+	if ok {
+		return &Client{Client: prev, Provider: p}
+	}
+
+	appName := "gconftest"
+	if prev != nil {
+		appName = prev.AppId()
+	}
+	c := NewClient(t, appName)
+	gconf.SetDefault(c.Client)
+	t.Cleanup(func() { gconf.SetDefault(prev) })
+	return c
+}
@@ -0,0 +1,99 @@
+package gconftest
+
+import (
+	"testing"
+
+	"github.com/guanaitong/gconf-go-client"
+)
+
+func TestClientSetValue(t *testing.T) {
+	c := NewClient(t, "app")
+	c.Provider.SetCollection("app", map[string]string{"flag": "off"})
+
+	var events []string
+	collection := c.GetCurrentConfigCollection()
+	collection.AddConfigChangeListener("flag", gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		events = append(events, oldValue+"->"+newValue)
+	}))
+
+	t.Run("override", func(t *testing.T) {
+		c.SetValue(t, "app", "flag", "on")
+		if v := collection.GetValue("flag").Raw(); v != "on" {
+			t.Fatalf("unexpected value %q", v)
+		}
+		c.SetValue(t, "app", "flag", "beta")
+	})
+	if v := collection.GetValue("flag").Raw(); v != "off" {
+		t.Fatalf("value should be restored, got %q", v)
+	}
+	want := []string{"off->on", "on->beta", "beta->on", "on->off"}
+	if len(events) != len(want) {
+		t.Fatalf("unexpected events %v", events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("unexpected events %v", events)
+		}
+	}
+
+	t.Run("new key", func(t *testing.T) {
+		c.SetValue(t, "app", "added", "1")
+		if v := collection.GetValue("added").Raw(); v != "1" {
+			t.Fatalf("unexpected value %q", v)
+		}
+	})
+	if _, ok := c.Provider.Get("app", "added"); ok {
+		t.Fatal("added key should be removed after the test")
+	}
+}
+
+func TestRemoveListener(t *testing.T) {
+	c := NewClient(t, "app")
+	collection := c.GetCurrentConfigCollection()
+
+	var keyEvents, anyEvents int
+	removeKey := collection.AddConfigChangeListener("flag", gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		keyEvents++
+	}))
+	removeAny := collection.AddCollectionChangeListener(gconf.ConfigChangeListenerFunc(func(key, oldValue, newValue string) {
+		anyEvents++
+	}))
+	c.SetValue(t, "app", "flag", "on")
+	if keyEvents != 1 || anyEvents != 1 {
+		t.Fatalf("unexpected events %d %d", keyEvents, anyEvents)
+	}
+	removeKey()
+	removeAny()
+	removeAny()
+	c.SetValue(t, "app", "flag", "off")
+	if keyEvents != 1 || anyEvents != 1 {
+		t.Fatalf("removed listeners should not be notified, got %d %d", keyEvents, anyEvents)
+	}
+}
+
+func TestClientSetValueParallel(t *testing.T) {
+	for _, v := range []string{"a", "b", "c"} {
+		v := v
+		t.Run(v, func(t *testing.T) {
+			t.Parallel()
+			c := NewClient(t, "app")
+			c.SetValue(t, "app", "key", v)
+			if got := c.GetCurrentConfigCollection().GetValue("key").Raw(); got != v {
+				t.Fatalf("unexpected value %q", got)
+			}
+		})
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	prev := gconf.Default()
+	t.Run("default", func(t *testing.T) {
+		SetValue(t, "other", "datasource.json", `{"dbName":"test"}`)
+		if v := gconf.GetConfigCollection("other").GetValue("datasource.json").Raw(); v != `{"dbName":"test"}` {
+			t.Fatalf("unexpected value %q", v)
+		}
+	})
+	if gconf.Default() != prev {
+		t.Fatal("default client should be restored")
+	}
+}
@@ -0,0 +1,95 @@
+// Package swap 管理随配置切换的连接池、客户端等资源：原子替换当前资源，
+// 被替换的资源保留一段时间供已取得它的调用方使用，之后关闭。
+// gconf_mysql、gconf_gorm及gconf_redis共用，保证各组件的切换及关闭行为一致
+package swap
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 随配置切换的资源
+type Value[E any] struct {
+	delay  time.Duration
+	close  func(*E) error
+	logger *slog.Logger
+
+	current atomic.Pointer[E]
+	done    chan struct{}
+
+	mux      sync.Mutex
+	closed   bool
+	draining map[*E]*time.Timer // 待关闭的旧资源
+}
+
+// 创建Value，被替换的资源在delay后由closeFn关闭，关闭失败时记录到logger
+func New[E any](delay time.Duration, closeFn func(*E) error, logger *slog.Logger) *Value[E] {
+	return &Value[E]{delay: delay, close: closeFn, logger: logger, done: make(chan struct{})}
+}
+
+// 当前资源，未设置时为nil，关闭后返回最后的资源(已关闭)
+func (v *Value[E]) Load() *E {
+	return v.current.Load()
+}
+
+// 替换当前资源，旧资源在delay后关闭。已关闭时不替换并返回false，调用方应自行关闭e
+func (v *Value[E]) Swap(e *E) bool {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	if v.closed {
+		return false
+	}
+	if old := v.current.Swap(e); old != nil {
+		v.drainLocked(old)
+	}
+	return true
+}
+
+// Close时关闭，关闭函数等待旧资源空闲时可据此放弃等待
+func (v *Value[E]) Done() <-chan struct{} {
+	return v.done
+}
+
+// 立即关闭当前及待关闭的资源，之后Swap返回false
+func (v *Value[E]) Close() error {
+	v.mux.Lock()
+	if v.closed {
+		v.mux.Unlock()
+		return nil
+	}
+	v.closed = true
+	close(v.done)
+	draining := v.draining
+	v.draining = nil
+	v.mux.Unlock()
+	var errs []error
+	for e, t := range draining {
+		t.Stop()
+		errs = append(errs, v.close(e))
+	}
+	if e := v.current.Load(); e != nil {
+		errs = append(errs, v.close(e))
+	}
+	return errors.Join(errs...)
+}
+
+func (v *Value[E]) drainLocked(old *E) {
+	if v.draining == nil {
+		v.draining = map[*E]*time.Timer{}
+	}
+	v.draining[old] = time.AfterFunc(v.delay, func() {
+		v.mux.Lock()
+		_, ok := v.draining[old]
+		delete(v.draining, old)
+		v.mux.Unlock()
+		if !ok {
+			return // 已由Close关闭
+		}
+		if err := v.close(old); err != nil {
+			v.logger.Warn("close previous", "error", err)
+		}
+	})
+}
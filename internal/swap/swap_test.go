@@ -0,0 +1,82 @@
+package swap
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type resource struct {
+	name string
+}
+
+type closer struct {
+	mux    sync.Mutex
+	closed []string
+}
+
+func (c *closer) close(r *resource) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.closed = append(c.closed, r.name)
+	return nil
+}
+
+func (c *closer) names() []string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return append([]string{}, c.closed...)
+}
+
+func TestSwap(t *testing.T) {
+	c := &closer{}
+	v := New(10*time.Millisecond, c.close, slog.Default())
+	if v.Load() != nil {
+		t.Fatal("empty value should load nil")
+	}
+	first, second := &resource{"first"}, &resource{"second"}
+	v.Swap(first)
+	v.Swap(second)
+	if v.Load() != second {
+		t.Fatal("should load the latest resource")
+	}
+	if len(c.names()) != 0 {
+		t.Fatal("previous resource closed before delay")
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(c.names()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("previous resource not closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if names := c.names(); len(names) != 1 || names[0] != "first" {
+		t.Errorf("closed %v", names)
+	}
+}
+
+func TestClose(t *testing.T) {
+	c := &closer{}
+	v := New(time.Hour, c.close, slog.Default())
+	v.Swap(&resource{"first"})
+	v.Swap(&resource{"second"})
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-v.Done():
+	default:
+		t.Error("done should be closed")
+	}
+	if names := c.names(); len(names) != 2 || names[1] != "second" {
+		t.Errorf("closed %v", names)
+	}
+	if v.Swap(&resource{"third"}) {
+		t.Error("swap after close should fail")
+	}
+	v.Close()
+	if len(c.names()) != 2 {
+		t.Errorf("closed twice %v", c.names())
+	}
+}